@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ===== COINGECKO PROVIDER =====
+
+type CoinGeckoProvider struct {
+	BaseURL string
+	limiter *RateLimiter
+}
+
+func NewCoinGeckoProvider(limiter *RateLimiter) *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		BaseURL: envOr("COINGECKO_BASE_URL", coinGeckoBaseURL()),
+		limiter: limiter,
+	}
+}
+
+func (p *CoinGeckoProvider) Name() string { return SOURCE_COINGECKO }
+
+func (p *CoinGeckoProvider) Capabilities() Capabilities {
+	// coins/markets accepts up to 250 ids, 50 keeps URLs short
+	return Capabilities{Historical: true, Quotes: true, MaxBatch: 50}
+}
+
+// FetchHistorical uses market_chart/range, which answers daily points for
+// ranges over 90 days and hourly ones below; either way one point per day is
+// kept
+func (p *CoinGeckoProvider) FetchHistorical(ctx context.Context, token TokenEntry, r DateRange) ([]HistoricalPoint, error) {
+	if token.CoinGeckoID == "" {
+		return nil, fmt.Errorf("%s has no coingecko_id", token.Key)
+	}
+
+	from := r.From.Unix()
+	to := r.To.AddDate(0, 0, 1).Unix() - 1
+	url := fmt.Sprintf("%s/coins/%s/market_chart/range?vs_currency=usd&from=%d&to=%d",
+		p.BaseURL, token.CoinGeckoID, from, to)
+
+	body, err := p.limiter.Get(ctx, url, coinGeckoHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var data CoinGeckoHistoricalResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+
+	fromDate, toDate := dayOf(r.From).Format("2006-01-02"), dayOf(r.To).Format("2006-01-02")
+	var points []HistoricalPoint
+	for _, pt := range dailyPoints(&data) {
+		if pt.Date >= fromDate && pt.Date <= toDate {
+			points = append(points, pt)
+		}
+	}
+	return points, nil
+}
+
+func (p *CoinGeckoProvider) FetchQuotes(ctx context.Context, tokens []TokenEntry) ([]Quote, error) {
+	var ids []string
+	keys := make(map[string]string) // coingecko id -> registry key
+	for _, t := range tokens {
+		if t.CoinGeckoID != "" {
+			ids = append(ids, t.CoinGeckoID)
+			keys[t.CoinGeckoID] = t.Key
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	url := fmt.Sprintf("%s/coins/markets?vs_currency=usd&ids=%s&order=market_cap_desc&sparkline=false&price_change_percentage=1h,24h,7d",
+		p.BaseURL, strings.Join(ids, ","))
+
+	body, err := p.limiter.Get(ctx, url, coinGeckoHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var data []CoinGeckoCurrentResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	quotes := make([]Quote, 0, len(data))
+	for _, coin := range data {
+		quotes = append(quotes, Quote{
+			Timestamp:         timestamp,
+			TokenKey:          keys[coin.ID],
+			TokenID:           coin.ID,
+			Symbol:            coin.Symbol,
+			Name:              coin.Name,
+			Price:             coin.CurrentPrice,
+			MarketCap:         coin.MarketCap,
+			Volume24h:         coin.TotalVolume,
+			High24h:           coin.High24h,
+			Low24h:            coin.Low24h,
+			PriceChange24h:    coin.PriceChange24h,
+			PercentChange24h:  coin.PriceChangePercentage24h,
+			CirculatingSupply: coin.CirculatingSupply,
+			TotalSupply:       coin.TotalSupply,
+			ATH:               coin.ATH,
+			ATHDate:           coin.ATHDate,
+		})
+	}
+	return quotes, nil
+}
+
+// coinGeckoDay is the UTC day a market_chart point is bucketed in. CoinGecko's
+// days are UTC, so the bucket must not move with the collector's time zone.
+func coinGeckoDay(timestamp int64) time.Time {
+	return dayOf(time.Unix(timestamp, 0))
+}
+
+// dailyPoints reduces a market_chart response to the earliest point of each
+// UTC day, sorted by time
+func dailyPoints(data *CoinGeckoHistoricalResponse) []HistoricalPoint {
+	byDate := make(map[string]HistoricalPoint)
+
+	for i, p := range data.Prices {
+		if len(p) < 2 {
+			continue
+		}
+		timestamp := int64(p[0] / 1000)
+		date := coinGeckoDay(timestamp).Format("2006-01-02")
+		if existing, ok := byDate[date]; ok && existing.Timestamp <= timestamp {
+			continue
+		}
+
+		pt := HistoricalPoint{Timestamp: timestamp, Date: date, Price: p[1]}
+		if i < len(data.MarketCaps) && len(data.MarketCaps[i]) >= 2 {
+			pt.MarketCap = data.MarketCaps[i][1]
+		}
+		if i < len(data.TotalVolumes) && len(data.TotalVolumes[i]) >= 2 {
+			pt.Volume = data.TotalVolumes[i][1]
+		}
+		byDate[date] = pt
+	}
+
+	points := make([]HistoricalPoint, 0, len(byDate))
+	for _, pt := range byDate {
+		points = append(points, pt)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points
+}
+
+// coinGeckoPlan resolves COINGECKO_PLAN, defaulting on whether a key is set
+func coinGeckoPlan() string {
+	switch COINGECKO_PLAN {
+	case "free", "demo", "pro":
+		return COINGECKO_PLAN
+	}
+	if COINGECKO_API_KEY != "" {
+		return "demo"
+	}
+	return "free"
+}
+
+func coinGeckoBaseURL() string {
+	if coinGeckoPlan() == "pro" {
+		return "https://pro-api.coingecko.com/api/v3"
+	}
+	return "https://api.coingecko.com/api/v3"
+}
+
+func coinGeckoHeaders() map[string]string {
+	headers := map[string]string{"Accept": "application/json"}
+	switch coinGeckoPlan() {
+	case "demo":
+		headers["x-cg-demo-api-key"] = COINGECKO_API_KEY
+	case "pro":
+		headers["x-cg-pro-api-key"] = COINGECKO_API_KEY
+	}
+	return headers
+}
+
+// CoinGeckoCoin is one entry of /coins/list
+type CoinGeckoCoin struct {
+	ID     string `json:"id"`
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+}
+
+// FetchCoinList returns every coin CoinGecko knows (one request)
+func (p *CoinGeckoProvider) FetchCoinList(ctx context.Context) ([]CoinGeckoCoin, error) {
+	body, err := p.limiter.Get(ctx, p.BaseURL+"/coins/list", coinGeckoHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var coins []CoinGeckoCoin
+	if err := json.Unmarshal(body, &coins); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	return coins, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===== COINMARKETCAP PROVIDER =====
+
+type CMCProvider struct {
+	BaseURL string
+	limiter *RateLimiter
+}
+
+func NewCMCProvider(limiter *RateLimiter) *CMCProvider {
+	return &CMCProvider{
+		BaseURL: envOr("CMC_BASE_URL", "https://pro-api.coinmarketcap.com"),
+		limiter: limiter,
+	}
+}
+
+func (p *CMCProvider) Name() string { return SOURCE_CMC }
+
+func (p *CMCProvider) Capabilities() Capabilities {
+	// Historical quotes need a paid plan
+	return Capabilities{Historical: false, Quotes: true, MaxBatch: 50}
+}
+
+func (p *CMCProvider) FetchHistorical(ctx context.Context, token TokenEntry, r DateRange) ([]HistoricalPoint, error) {
+	return nil, ErrNotSupported
+}
+
+// FetchQuotes asks for numeric CMC ids, symbols are ambiguous on CMC
+func (p *CMCProvider) FetchQuotes(ctx context.Context, tokens []TokenEntry) ([]Quote, error) {
+	var ids []string
+	keys := make(map[int]string) // cmc id -> registry key
+	for _, t := range tokens {
+		if t.CMC.ID != 0 {
+			ids = append(ids, strconv.Itoa(t.CMC.ID))
+			keys[t.CMC.ID] = t.Key
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	url := fmt.Sprintf("%s/v1/cryptocurrency/quotes/latest?id=%s&convert=USD", p.BaseURL, strings.Join(ids, ","))
+
+	body, err := p.limiter.Get(ctx, url, map[string]string{
+		"X-CMC_PRO_API_KEY": CMC_API_KEY,
+		"Accept":            "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var data CMCQuoteResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if data.Status.ErrorCode != 0 {
+		return nil, fmt.Errorf("CMC error %d: %s", data.Status.ErrorCode, data.Status.ErrorMessage)
+	}
+
+	timestamp := time.Now().Unix()
+	quotes := make([]Quote, 0, len(data.Data))
+	for _, coin := range data.Data {
+		quote := coin.Quote["USD"]
+		quotes = append(quotes, Quote{
+			Timestamp:          timestamp,
+			TokenKey:           keys[coin.ID],
+			TokenID:            coin.Symbol,
+			Symbol:             coin.Symbol,
+			Name:               coin.Name,
+			Slug:               coin.Slug,
+			Price:              quote.Price,
+			MarketCap:          quote.MarketCap,
+			Volume24h:          quote.Volume24h,
+			VolumeChange24h:    quote.VolumeChange24h,
+			PercentChange1h:    quote.PercentChange1h,
+			PercentChange24h:   quote.PercentChange24h,
+			PercentChange7d:    quote.PercentChange7d,
+			MarketCapDominance: quote.MarketCapDominance,
+			CirculatingSupply:  coin.CirculatingSupply,
+			TotalSupply:        coin.TotalSupply,
+			MaxSupply:          coin.MaxSupply,
+			LastUpdated:        quote.LastUpdated,
+		})
+	}
+	return quotes, nil
+}
+
+// CMCMapEntry is one entry of /v1/cryptocurrency/map
+type CMCMapEntry struct {
+	ID     int    `json:"id"`
+	Rank   int    `json:"rank"`
+	Name   string `json:"name"`
+	Symbol string `json:"symbol"`
+	Slug   string `json:"slug"`
+}
+
+// FetchIDMap returns the CMC id map entries for the given symbols. A symbol
+// can match several coins; callers pick by id or slug.
+func (p *CMCProvider) FetchIDMap(ctx context.Context, symbols []string) ([]CMCMapEntry, error) {
+	url := fmt.Sprintf("%s/v1/cryptocurrency/map?symbol=%s", p.BaseURL, strings.Join(symbols, ","))
+
+	body, err := p.limiter.Get(ctx, url, map[string]string{
+		"X-CMC_PRO_API_KEY": CMC_API_KEY,
+		"Accept":            "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Data   []CMCMapEntry `json:"data"`
+		Status CMCStatus     `json:"status"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if data.Status.ErrorCode != 0 {
+		return nil, fmt.Errorf("CMC error %d: %s", data.Status.ErrorCode, data.Status.ErrorMessage)
+	}
+	return data.Data, nil
+}
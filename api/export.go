@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ===== PARQUET EXPORT =====
+// `export --format parquet` writes the CSV datasets as typed, compressed
+// Parquet so the notebook can load them without re-parsing text. Rows go
+// through the normalizer's standard schema and are partitioned Hive-style:
+//
+//	<out>/data_source=<source>/month=<YYYY-MM>/part-0.parquet
+//
+// so pandas/pyarrow can prune by source and month on read.
+
+var (
+	PARQUET_DIR            = "./data/parquet"
+	PARQUET_ROWS_PER_GROUP = int64(10000)
+)
+
+// ParquetRow is UnifiedRow with real column types: timestamps as
+// TIMESTAMP(ms), dates as DATE, and missing numbers as nulls
+type ParquetRow struct {
+	Timestamp          time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Date               int32     `parquet:"date,date"` // days since 1970-01-01
+	TokenID            string    `parquet:"token_id,dict"`
+	TokenSymbol        string    `parquet:"token_symbol,dict"`
+	TokenName          string    `parquet:"token_name,dict"`
+	Price              *float64  `parquet:"price,optional"`
+	Open               *float64  `parquet:"open,optional"`
+	High               *float64  `parquet:"high,optional"`
+	Low                *float64  `parquet:"low,optional"`
+	Close              *float64  `parquet:"close,optional"`
+	MarketCap          *float64  `parquet:"market_cap,optional"`
+	Volume24h          *float64  `parquet:"volume_24h,optional"`
+	High24h            *float64  `parquet:"high_24h,optional"`
+	Low24h             *float64  `parquet:"low_24h,optional"`
+	PriceChange24h     *float64  `parquet:"price_change_24h,optional"`
+	PriceChangePct24h  *float64  `parquet:"price_change_pct_24h,optional"`
+	PercentChange1h    *float64  `parquet:"percent_change_1h,optional"`
+	PercentChange7d    *float64  `parquet:"percent_change_7d,optional"`
+	CirculatingSupply  *float64  `parquet:"circulating_supply,optional"`
+	TotalSupply        *float64  `parquet:"total_supply,optional"`
+	MaxSupply          *float64  `parquet:"max_supply,optional"`
+	MarketCapDominance *float64  `parquet:"market_cap_dominance,optional"`
+	ATH                *float64  `parquet:"ath,optional"`
+	ATHDate            string    `parquet:"ath_date,optional"`
+	DataSource         string    `parquet:"data_source,dict"`
+	OriginalSource     string    `parquet:"original_source,dict"`
+	Volatility7d       *float64  `parquet:"volatility_7d,optional"`
+	OutlierFlags       string    `parquet:"outlier_flags,optional"`
+	IsImputed          bool      `parquet:"is_imputed"`
+	ImputedFields      string    `parquet:"imputed_fields,optional"`
+}
+
+// nullable maps NaN to a Parquet null
+func nullable(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+func toParquetRow(r UnifiedRow) ParquetRow {
+	date, _ := time.Parse("2006-01-02", r.Date)
+	return ParquetRow{
+		Timestamp:          time.Unix(r.Timestamp, 0).UTC(),
+		Date:               int32(date.Unix() / 86400),
+		TokenID:            r.TokenID,
+		TokenSymbol:        r.TokenSymbol,
+		TokenName:          r.TokenName,
+		Price:              nullable(r.Price),
+		Open:               nullable(r.Open),
+		High:               nullable(r.High),
+		Low:                nullable(r.Low),
+		Close:              nullable(r.Close),
+		MarketCap:          nullable(r.MarketCap),
+		Volume24h:          nullable(r.Volume24h),
+		High24h:            nullable(r.High24h),
+		Low24h:             nullable(r.Low24h),
+		PriceChange24h:     nullable(r.PriceChange24h),
+		PriceChangePct24h:  nullable(r.PriceChangePct24h),
+		PercentChange1h:    nullable(r.PercentChange1h),
+		PercentChange7d:    nullable(r.PercentChange7d),
+		CirculatingSupply:  nullable(r.CirculatingSupply),
+		TotalSupply:        nullable(r.TotalSupply),
+		MaxSupply:          nullable(r.MaxSupply),
+		MarketCapDominance: nullable(r.MarketCapDominance),
+		ATH:                nullable(r.ATH),
+		ATHDate:            r.ATHDate,
+		DataSource:         r.DataSource,
+		OriginalSource:     r.OriginalSource,
+		Volatility7d:       nullable(r.Volatility7d),
+		OutlierFlags:       r.OutlierFlags,
+		IsImputed:          r.Imputed,
+		ImputedFields:      r.ImputedFields,
+	}
+}
+
+// partitionRows groups rows by "data_source=<source>/month=<YYYY-MM>" and
+// sorts each group by token and time so row-group min/max stats are tight
+func partitionRows(rows []UnifiedRow) map[string][]UnifiedRow {
+	parts := make(map[string][]UnifiedRow)
+	for _, r := range rows {
+		month := "unknown"
+		if len(r.Date) >= 7 {
+			month = r.Date[:7]
+		}
+		key := filepath.Join("data_source="+r.DataSource, "month="+month)
+		parts[key] = append(parts[key], r)
+	}
+	for _, part := range parts {
+		sort.SliceStable(part, func(i, j int) bool {
+			if part[i].TokenID != part[j].TokenID {
+				return part[i].TokenID < part[j].TokenID
+			}
+			return part[i].Timestamp < part[j].Timestamp
+		})
+	}
+	return parts
+}
+
+func writeParquetFile(path string, rows []UnifiedRow) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	writer := parquet.NewGenericWriter[ParquetRow](file,
+		parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(PARQUET_ROWS_PER_GROUP),
+		parquet.DataPageStatistics(true),
+		parquet.CreatedBy("safeswap-api", "", ""),
+	)
+
+	batch := make([]ParquetRow, 0, len(rows))
+	for _, r := range rows {
+		batch = append(batch, toParquetRow(r))
+	}
+	if _, err := writer.Write(batch); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runExport implements the `export` command
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "parquet", "output format (parquet)")
+	out := fs.String("out", PARQUET_DIR, "output directory")
+	source := fs.String("source", "", "only export this data_source (e.g. coingecko_api_full)")
+	clean := fs.Bool("clean", false, "apply the normalize cleaning (drop bad prices, dedupe token/day) first")
+	imputeConfig := imputeFlags(fs)
+	outlierConfig := outlierFlags(fs)
+	fs.Parse(args)
+
+	if *format != "parquet" {
+		fmt.Printf("❌ Unsupported export format %q (supported: parquet)\n", *format)
+		return 2
+	}
+	impute, err := imputeConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	outliers, err := outlierConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	if impute.Enabled && !*clean {
+		fmt.Println("❌ --impute needs --clean (rows must be one per token and day, in order)")
+		return 2
+	}
+	if outliers.Action != OUTLIER_OFF && !*clean {
+		fmt.Println("❌ --outliers needs --clean (rows must be one per token and day, in order)")
+		return 2
+	}
+
+	logFile := setup()
+	defer logFile.Close()
+
+	fmt.Printf("📦 Exporting CSV datasets to Parquet: %s\n", *out)
+
+	var rows []UnifiedRow
+	for _, in := range UNIFIED_INPUTS {
+		if *source != "" && in.DataSource != *source {
+			continue
+		}
+		inputRows, err := normalizeInput(in, TOKEN_REGISTRY)
+		if os.IsNotExist(err) {
+			fmt.Printf("   ⏭️  %s: not collected yet\n", in.DataSource)
+			continue
+		}
+		if err != nil {
+			log.Printf("Export: skipping %s: %v", in.Path, err)
+			fmt.Printf("   ❌ %s: %v\n", in.Path, err)
+			continue
+		}
+		fmt.Printf("   ✅ %s: %d records\n", in.DataSource, len(inputRows))
+		rows = append(rows, inputRows...)
+	}
+	if len(rows) == 0 {
+		fmt.Println("❌ Nothing to export")
+		return 1
+	}
+
+	if *clean {
+		var stats NormalizeStats
+		rows = cleanUnified(rows, TOKEN_REGISTRY, &stats)
+	}
+	if impute.Enabled {
+		var imputed ImputeStats
+		rows, imputed = applyImputeStage(rows, impute)
+		printImputeSummary(impute, imputed)
+	}
+	if *clean {
+		addVolatility(rows)
+	}
+	if outliers.Action != OUTLIER_OFF {
+		events := applyOutlierStage(rows, outliers)
+		printOutlierSummary(outliers, len(rows), events)
+	}
+
+	parts := partitionRows(rows)
+	keys := make([]string, 0, len(parts))
+	for key := range parts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := filepath.Join(*out, key, "part-0.parquet")
+		if err := writeParquetFile(path, parts[key]); err != nil {
+			log.Printf("Export: writing %s failed: %v", path, err)
+			fmt.Printf("❌ %s: %v\n", path, err)
+			return 1
+		}
+		fmt.Printf("   📄 %-60s %6d rows\n", strings.TrimPrefix(path, *out+string(filepath.Separator)), len(parts[key]))
+	}
+
+	fmt.Printf("\n✅ Exported %d records into %d partitions\n", len(rows), len(parts))
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ===== FEATURES =====
+// Columns derived from the cleaned rows, before any outlier handling (the
+// notebook's Cell 7 runs before Cell 9). volatility_7d is the sample standard
+// deviation of a token's one-day returns over the 7 calendar days ending at
+// the row. A return is only taken between consecutive days, so a gap in the
+// history leaves returns out instead of stretching one over several days.
+
+const VOLATILITY_DAYS = 7
+
+// dailyReturn is one token's price change from the previous calendar day
+type dailyReturn struct {
+	day   time.Time
+	value float64
+}
+
+// addVolatility fills volatility_7d on rows sorted by token and time, one
+// per token and day (cleanUnified's output)
+func addVolatility(rows []UnifiedRow) {
+	var (
+		token   string
+		prev    *UnifiedRow
+		returns []dailyReturn
+	)
+	for i := range rows {
+		r := &rows[i]
+		if r.TokenID != token {
+			token, prev, returns = r.TokenID, nil, nil
+		}
+		day, ok := rowDay(*r)
+		if !ok {
+			r.Volatility7d = math.NaN()
+			continue
+		}
+
+		if prev != nil && daysBetween(*prev, *r) == 1 {
+			returns = append(returns, dailyReturn{day, r.Price/prev.Price - 1})
+		}
+		prev = r
+
+		// Keep the returns of days (day-6 .. day]
+		from := day.AddDate(0, 0, -(VOLATILITY_DAYS - 1))
+		for len(returns) > 0 && returns[0].day.Before(from) {
+			returns = returns[1:]
+		}
+		values := make([]float64, len(returns))
+		for j, ret := range returns {
+			values[j] = ret.value
+		}
+		r.Volatility7d = sampleStd(values)
+	}
+}
+
+// sampleStd is the ddof=1 standard deviation of the non-missing values, NaN
+// below two of them
+func sampleStd(window []float64) float64 {
+	var sum, n float64
+	for _, v := range window {
+		if !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n < 2 {
+		return math.NaN()
+	}
+	mean := sum / n
+	var ss float64
+	for _, v := range window {
+		if !math.IsNaN(v) {
+			ss += (v - mean) * (v - mean)
+		}
+	}
+	return math.Sqrt(ss / (n - 1))
+}
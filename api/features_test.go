@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddVolatility(t *testing.T) {
+	row := func(token, date string, price float64) UnifiedRow {
+		r := newUnifiedRow()
+		r.TokenID, r.Date, r.Price = token, date, price
+		return r
+	}
+	rows := []UnifiedRow{
+		row("a", "2024-01-01", 100),
+		row("a", "2024-01-02", 110), // +10%
+		row("a", "2024-01-03", 99),  // -10%
+		row("a", "2024-01-06", 200), // gap: no return
+		row("a", "2024-01-07", 220), // +10%
+		row("a", "2024-01-20", 100), // earlier returns out of the window
+		row("b", "2024-01-02", 50),  // another token starts afresh
+	}
+	addVolatility(rows)
+
+	std := math.Sqrt(0.02) // sample std of {0.1, -0.1}
+	std3 := math.Sqrt(((0.1-0.1/3)*(0.1-0.1/3)*2 + (-0.1-0.1/3)*(-0.1-0.1/3)) / 2)
+	want := []float64{math.NaN(), math.NaN(), std, std, std3, math.NaN(), math.NaN()}
+	for i, w := range want {
+		got := rows[i].Volatility7d
+		if math.IsNaN(w) != math.IsNaN(got) || (!math.IsNaN(w) && math.Abs(got-w) > 1e-12) {
+			t.Errorf("row %d (%s %s): volatility %v, want %v", i, rows[i].TokenID, rows[i].Date, got, w)
+		}
+	}
+}
+
+func TestSampleStd(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, math.NaN()},
+		{[]float64{1}, math.NaN()},
+		{[]float64{1, math.NaN()}, math.NaN()},
+		{[]float64{1, 3}, math.Sqrt(2)},
+		{[]float64{2, 4, 4, 4, 5, 5, 7, 9}, math.Sqrt(32.0 / 7)},
+	}
+	for _, tt := range tests {
+		got := sampleStd(tt.values)
+		if math.IsNaN(tt.want) != math.IsNaN(got) || (!math.IsNaN(got) && math.Abs(got-tt.want) > 1e-12) {
+			t.Errorf("sampleStd(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// ===== GAP DETECTION =====
+// The backfill only tracks the outer edges of each token's coverage. Any day
+// that went missing inside it (a 429 mid-run, a crashed run, a day CoinGecko
+// did not return) is found here by reading the stored history back and
+// filled with targeted range requests.
+
+// Days this recent are not marked unavailable when the provider skips them:
+// it may just not have published them yet
+const UNAVAILABLE_GRACE_DAYS = 3
+
+type TokenGaps struct {
+	Token       TokenEntry
+	Present     map[string]bool // dates already on disk
+	Missing     []DateRange
+	Unavailable int // days skipped because the provider has no data for them
+}
+
+func (g TokenGaps) MissingDays() int {
+	days := 0
+	for _, r := range g.Missing {
+		days += r.Days()
+	}
+	return days
+}
+
+// analyseCoinGeckoGaps reads the CoinGecko history back from the store and
+// returns, per token, the calendar days of want that have no row and are not
+// known to be unavailable
+func analyseCoinGeckoGaps(store Store, state *RunState, tokens []TokenEntry, want DateRange) ([]TokenGaps, error) {
+	present, err := store.HistoricalDates(SOURCE_COINGECKO)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TokenGaps
+	for _, token := range tokens {
+		gaps := TokenGaps{Token: token, Present: present[token.Key]}
+		if gaps.Present == nil {
+			gaps.Present = make(map[string]bool)
+		}
+		unavailable := state.Get(SOURCE_COINGECKO, token.Key).Unavailable
+
+		var open *DateRange
+		for day := want.From; !day.After(want.To); day = day.AddDate(0, 0, 1) {
+			date := day.Format("2006-01-02")
+			if gaps.Present[date] {
+				open = nil
+				continue
+			}
+			if slices.Contains(unavailable, date) {
+				gaps.Unavailable++
+				open = nil
+				continue
+			}
+			if open == nil {
+				gaps.Missing = append(gaps.Missing, DateRange{From: day, To: day})
+				open = &gaps.Missing[len(gaps.Missing)-1]
+			} else {
+				open.To = day
+			}
+		}
+
+		result = append(result, gaps)
+	}
+
+	return result, nil
+}
+
+// fillCoinGeckoGaps requests each missing range and appends the recovered
+// days to the store. Dates already on disk are never written again. The CSV
+// store appends, so its rows are not in date order afterwards; readers sort
+// by timestamp (normalize does). Days the provider answers without are
+// recorded in the run state and not requested again.
+func fillCoinGeckoGaps(ctx context.Context, p MarketDataProvider, state *RunState, gaps []TokenGaps) int {
+	totalRecords := 0
+	recent := time.Now().AddDate(0, 0, -UNAVAILABLE_GRACE_DAYS).Format("2006-01-02")
+
+	for i, g := range gaps {
+		if ctx.Err() != nil {
+			fmt.Println("\n⏹️  Stopping gap fill (shutting down)")
+			break
+		}
+		if len(g.Missing) == 0 {
+			continue
+		}
+		fmt.Printf("\n[%d/%d] Filling %d missing days for %s...\n", i+1, len(gaps), g.MissingDays(), g.Token.Key)
+
+		st := state.Get(p.Name(), g.Token.Key)
+		for _, r := range g.Missing {
+			points, err := p.FetchHistorical(ctx, g.Token, r)
+			if err != nil {
+				log.Printf("Gap fill failed for %s %s: %v", g.Token.Key, r, err)
+				fmt.Printf("  ❌ %s: %s\n", r, shortError(err))
+				continue
+			}
+
+			returned := make(map[string]bool)
+			var fresh []HistoricalPoint
+			for _, pt := range points {
+				returned[pt.Date] = true
+				if !g.Present[pt.Date] {
+					fresh = append(fresh, pt)
+					g.Present[pt.Date] = true
+				}
+			}
+
+			var none []string
+			for day := r.From; !day.After(r.To); day = day.AddDate(0, 0, 1) {
+				if date := day.Format("2006-01-02"); !returned[date] && date < recent {
+					none = append(none, date)
+				}
+			}
+			if len(none) > 0 {
+				st.MarkUnavailable(none...)
+				log.Printf("Gap fill: %s has no CoinGecko data for %v", g.Token.Key, none)
+			}
+
+			count, _, _ := writeHistorical(p.Name(), g.Token, r, fresh)
+			totalRecords += count
+			fmt.Printf("  ✅ %s: recovered %d/%d days", r, count, r.Days())
+			if len(none) > 0 {
+				fmt.Printf(", %d not available", len(none))
+			}
+			fmt.Println()
+		}
+
+		st.touch()
+		if err := state.Save(RUN_STATE_PATH); err != nil {
+			log.Printf("Could not save run state: %v", err)
+		}
+	}
+
+	fmt.Printf("\n📊 Total gap records recovered: %d\n", totalRecords)
+	return totalRecords
+}
+
+// runGaps implements the `gaps` command: report missing days per token and,
+// with --fill, recover them
+func runGaps(args []string) int {
+	fs := flag.NewFlagSet("gaps", flag.ExitOnError)
+	fill := fs.Bool("fill", false, "request the missing ranges and write them to the store")
+	days := fs.Int("days", DAYS_HISTORICAL, "how many days back to check")
+	retry := fs.Bool("retry-unavailable", false, "also ask again for days CoinGecko had no data for")
+	fs.Parse(args)
+
+	logFile := setup()
+	defer logFile.Close()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	want := DateRange{From: today.AddDate(0, 0, -*days), To: today.AddDate(0, 0, -1)}
+
+	STORE = openStore(fileExists(CG_CSV_PATH), fileExists(CMC_CSV_PATH))
+	defer STORE.Close()
+	state := openRunState(STORE)
+	if *retry {
+		for _, token := range TOKENS {
+			state.Get(SOURCE_COINGECKO, token.Key).Unavailable = nil
+		}
+	}
+	loadMetadata(context.Background(), false)
+
+	fmt.Printf("🔍 Checking CoinGecko history for missing days in %s\n", want)
+
+	gaps, err := analyseCoinGeckoGaps(STORE, state, TOKENS, want)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	missingTotal := 0
+	for _, g := range gaps {
+		missingTotal += g.MissingDays()
+		unavailable := ""
+		if g.Unavailable > 0 {
+			unavailable = fmt.Sprintf(" (%d days unavailable upstream)", g.Unavailable)
+		}
+		if len(g.Missing) == 0 {
+			fmt.Printf("  ✅ %-20s complete%s\n", g.Token.Key, unavailable)
+			continue
+		}
+		fmt.Printf("  ⚠️  %-20s %d days missing in %d range(s)%s\n", g.Token.Key, g.MissingDays(), len(g.Missing), unavailable)
+		for _, r := range g.Missing {
+			fmt.Printf("       %s\n", r)
+		}
+	}
+
+	if missingTotal == 0 {
+		fmt.Println("\n✅ No gaps found")
+		return 0
+	}
+	if !*fill {
+		fmt.Printf("\n📅 %d missing days. Run `go run . gaps --fill` to recover them.\n", missingTotal)
+		return 0
+	}
+
+	fillCoinGeckoGaps(context.Background(), NewCoinGeckoProvider(CG_LIMITER), state, gaps)
+	fmt.Println("\n📉 API budget used this run:")
+	CG_LIMITER.Report()
+	QUARANTINE.Report()
+	return 0
+}
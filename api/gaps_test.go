@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// datesStore serves HistoricalDates from a fixed map; the rest of Store is
+// not used by the analyser
+type datesStore struct {
+	Store
+	dates map[string]map[string]bool
+}
+
+func (s datesStore) HistoricalDates(source string) (map[string]map[string]bool, error) {
+	return s.dates, nil
+}
+
+func TestAnalyseCoinGeckoGaps(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.Local) }
+	want := DateRange{From: day(1), To: day(10)}
+	present := func(days ...int) map[string]bool {
+		m := make(map[string]bool)
+		for _, d := range days {
+			m[day(d).Format("2006-01-02")] = true
+		}
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		present     map[string]bool
+		unavailable []string
+		want        []string
+		skipped     int
+	}{
+		{"empty history", nil, nil, []string{"2024-03-01..2024-03-10"}, 0},
+		{"contiguous data", present(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), nil, nil, 0},
+		{"gap at the start", present(4, 5, 6, 7, 8, 9, 10), nil, []string{"2024-03-01..2024-03-03"}, 0},
+		{"gap at the end", present(1, 2, 3, 4, 5, 6, 7, 8), nil, []string{"2024-03-09..2024-03-10"}, 0},
+		{
+			"single-day gaps", present(1, 3, 4, 5, 6, 7, 8, 10), nil,
+			[]string{"2024-03-02..2024-03-02", "2024-03-09..2024-03-09"}, 0,
+		},
+		{
+			"adjacent missing days merge", present(1, 2, 6, 7, 8, 9, 10), nil,
+			[]string{"2024-03-03..2024-03-05"}, 0,
+		},
+		{
+			"unavailable days split a gap", present(1, 2, 6, 7, 8, 9, 10), []string{"2024-03-04"},
+			[]string{"2024-03-03..2024-03-03", "2024-03-05..2024-03-05"}, 1,
+		},
+	}
+	for _, tt := range tests {
+		state := &RunState{Backfill: make(map[string]map[string]*BackfillStatus)}
+		state.Get(SOURCE_COINGECKO, "eth").Unavailable = tt.unavailable
+		store := datesStore{dates: map[string]map[string]bool{"eth": tt.present}}
+
+		gaps, err := analyseCoinGeckoGaps(store, state, []TokenEntry{{Key: "eth"}}, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range gaps[0].Missing {
+			got = append(got, r.String())
+		}
+		if !slices.Equal(got, tt.want) || gaps[0].Unavailable != tt.skipped {
+			t.Errorf("%s: missing %v (%d unavailable), want %v (%d)", tt.name, got, gaps[0].Unavailable, tt.want, tt.skipped)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ===== IMPUTATION STAGE =====
+// Go port of the notebook's missing-value handling (Cell 5), with the
+// provenance the notebook does not keep. Per token, on rows sorted by time:
+//
+//  1. Missing days are added as copies of the day before (ffill), but only
+//     for gaps of at most --impute-max-gap days; longer gaps stay missing
+//     and are reported.
+//  2. Missing values of the imputed columns are carried forward from the
+//     last observed value (ffill) or, before the first one, back from the
+//     next (bfill), again only within the gap limit.
+//  3. Missing 24h price changes are derived from the previous day's price.
+//
+// Every imputed field is named with its method in imputed_fields
+// ("volume_24h:ffill;price_change_24h:derived") and is_imputed is set.
+
+const (
+	IMPUTE_FFILL   = "ffill"
+	IMPUTE_BFILL   = "bfill"
+	IMPUTE_DERIVED = "derived"
+
+	// data_source of the rows added for missing days
+	IMPUTED_SOURCE = "imputed"
+)
+
+var (
+	IMPUTE_MAX_GAP_DAYS = 3
+	IMPUTE_COLUMNS      = "volume_24h,market_cap"
+)
+
+// IMPUTE_FIELDS are the columns the stage can carry forward or back
+var IMPUTE_FIELDS = map[string]func(r *UnifiedRow) *float64{
+	"price":              func(r *UnifiedRow) *float64 { return &r.Price },
+	"volume_24h":         func(r *UnifiedRow) *float64 { return &r.Volume24h },
+	"market_cap":         func(r *UnifiedRow) *float64 { return &r.MarketCap },
+	"circulating_supply": func(r *UnifiedRow) *float64 { return &r.CirculatingSupply },
+	"total_supply":       func(r *UnifiedRow) *float64 { return &r.TotalSupply },
+	"max_supply":         func(r *UnifiedRow) *float64 { return &r.MaxSupply },
+}
+
+type ImputeConfig struct {
+	Enabled bool
+	Columns []string
+	MaxGap  int // days
+}
+
+// imputeFlags registers the stage's options on a command's flag set
+func imputeFlags(fs *flag.FlagSet) func() (ImputeConfig, error) {
+	enabled := fs.Bool("impute", false, "fill missing days and values per token (see impute.go)")
+	columns := fs.String("impute-columns", IMPUTE_COLUMNS, "comma-separated columns to ffill/bfill")
+	maxGap := fs.Int("impute-max-gap", IMPUTE_MAX_GAP_DAYS, "longest gap in days that is imputed")
+
+	return func() (ImputeConfig, error) {
+		cfg := ImputeConfig{Enabled: *enabled, MaxGap: *maxGap}
+		for _, col := range strings.Split(*columns, ",") {
+			col = strings.TrimSpace(col)
+			if _, ok := IMPUTE_FIELDS[col]; !ok {
+				return cfg, fmt.Errorf("unknown impute column %q", col)
+			}
+			cfg.Columns = append(cfg.Columns, col)
+		}
+		if cfg.MaxGap < 1 {
+			return cfg, fmt.Errorf("--impute-max-gap must be at least 1")
+		}
+		return cfg, nil
+	}
+}
+
+// ImputeStats counts the stage's work; Refused lists the gaps left open
+type ImputeStats struct {
+	RowsAdded int
+	Fields    map[string]int // column:method -> values filled
+	Refused   []string       // "token from..to (n days)"
+}
+
+// markImputed records that a field of the row was filled by method
+func markImputed(r *UnifiedRow, column, method string) {
+	r.Imputed = true
+	if r.ImputedFields != "" {
+		r.ImputedFields += ";"
+	}
+	r.ImputedFields += column + ":" + method
+}
+
+func rowDay(r UnifiedRow) (time.Time, bool) {
+	d, err := time.Parse("2006-01-02", r.Date)
+	return d, err == nil
+}
+
+// daysBetween is the number of days from a to b
+func daysBetween(a, b UnifiedRow) int {
+	da, okA := rowDay(a)
+	db, okB := rowDay(b)
+	if !okA || !okB {
+		return 0
+	}
+	return int(db.Sub(da).Hours() / 24)
+}
+
+// applyImputeStage runs the stage over rows sorted by token and time and
+// returns the rows with missing days added
+func applyImputeStage(rows []UnifiedRow, cfg ImputeConfig) ([]UnifiedRow, ImputeStats) {
+	stats := ImputeStats{Fields: make(map[string]int)}
+
+	var out []UnifiedRow
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].TokenID == rows[start].TokenID {
+			end++
+		}
+		series := fillMissingDays(rows[start:end], cfg, &stats)
+		fillColumns(series, cfg, &stats)
+		derivePriceChanges(series, &stats)
+		out = append(out, series...)
+		start = end
+	}
+	return out, stats
+}
+
+// fillMissingDays adds a copy of the previous day for each missing day of a
+// gap no longer than the limit
+func fillMissingDays(series []UnifiedRow, cfg ImputeConfig, stats *ImputeStats) []UnifiedRow {
+	var out []UnifiedRow
+	for i, r := range series {
+		if i > 0 {
+			prev := series[i-1]
+			missing := daysBetween(prev, r) - 1
+			switch {
+			case missing > cfg.MaxGap:
+				stats.Refused = append(stats.Refused, fmt.Sprintf("%s %s..%s (%d days)",
+					r.TokenID, prev.Date, r.Date, missing))
+			case missing > 0:
+				day, _ := rowDay(prev)
+				for k := 1; k <= missing; k++ {
+					fill := newUnifiedRow()
+					fill.Timestamp = prev.Timestamp + int64(k)*86400
+					fill.Date = day.AddDate(0, 0, k).Format("2006-01-02")
+					fill.TokenID, fill.TokenSymbol, fill.TokenName = prev.TokenID, prev.TokenSymbol, prev.TokenName
+					fill.DataSource = IMPUTED_SOURCE
+
+					// The price always; a column listed twice is filled once
+					for _, col := range append([]string{"price"}, cfg.Columns...) {
+						v := *IMPUTE_FIELDS[col](&prev)
+						if math.IsNaN(v) || !math.IsNaN(*IMPUTE_FIELDS[col](&fill)) {
+							continue
+						}
+						*IMPUTE_FIELDS[col](&fill) = v
+						markImputed(&fill, col, IMPUTE_FFILL)
+						stats.Fields[col+":"+IMPUTE_FFILL]++
+					}
+					out = append(out, fill)
+					stats.RowsAdded++
+				}
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// fillColumns carries observed values forward, and back before the first
+// observation, within the gap limit
+func fillColumns(series []UnifiedRow, cfg ImputeConfig, stats *ImputeStats) {
+	for _, col := range cfg.Columns {
+		field := IMPUTE_FIELDS[col]
+
+		last := -1 // index of the last observed value
+		for i := range series {
+			if !math.IsNaN(*field(&series[i])) {
+				last = i
+				continue
+			}
+			if last >= 0 && daysBetween(series[last], series[i]) <= cfg.MaxGap {
+				*field(&series[i]) = *field(&series[last])
+				markImputed(&series[i], col, IMPUTE_FFILL)
+				stats.Fields[col+":"+IMPUTE_FFILL]++
+			}
+		}
+
+		// Leading values have nothing to carry forward
+		first := -1
+		for i := range series {
+			if !math.IsNaN(*field(&series[i])) {
+				first = i
+				break
+			}
+		}
+		for i := 0; i < first; i++ {
+			if daysBetween(series[i], series[first]) <= cfg.MaxGap {
+				*field(&series[i]) = *field(&series[first])
+				markImputed(&series[i], col, IMPUTE_BFILL)
+				stats.Fields[col+":"+IMPUTE_BFILL]++
+			}
+		}
+	}
+}
+
+// derivePriceChanges fills missing 24h changes from the previous day's price
+func derivePriceChanges(series []UnifiedRow, stats *ImputeStats) {
+	for i := 1; i < len(series); i++ {
+		r, prev := &series[i], series[i-1]
+		if daysBetween(prev, *r) != 1 || !(prev.Price > 0) || math.IsNaN(r.Price) {
+			continue
+		}
+		if math.IsNaN(r.PriceChange24h) {
+			r.PriceChange24h = r.Price - prev.Price
+			markImputed(r, "price_change_24h", IMPUTE_DERIVED)
+			stats.Fields["price_change_24h:"+IMPUTE_DERIVED]++
+		}
+		if math.IsNaN(r.PriceChangePct24h) {
+			r.PriceChangePct24h = (r.Price/prev.Price - 1) * 100
+			markImputed(r, "price_change_pct_24h", IMPUTE_DERIVED)
+			stats.Fields["price_change_pct_24h:"+IMPUTE_DERIVED]++
+		}
+	}
+}
+
+func printImputeSummary(cfg ImputeConfig, stats ImputeStats) {
+	fmt.Printf("\n🩹 IMPUTATION (gaps up to %d days)\n", cfg.MaxGap)
+	fmt.Printf("   Added %d rows for missing days\n", stats.RowsAdded)
+
+	keys := make([]string, 0, len(stats.Fields))
+	for key := range stats.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("   %-30s %6d\n", key, stats.Fields[key])
+	}
+
+	if len(stats.Refused) > 0 {
+		fmt.Printf("   ⚠️  %d gaps longer than %d days left missing:\n", len(stats.Refused), cfg.MaxGap)
+		for _, gap := range stats.Refused {
+			fmt.Printf("      - %s\n", gap)
+		}
+	}
+}
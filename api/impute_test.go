@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestApplyImputeStage(t *testing.T) {
+	nan := math.NaN()
+	type in struct {
+		date          string
+		price, volume float64
+	}
+	type want struct {
+		date          string
+		price, volume float64
+		fields        string
+	}
+	tests := []struct {
+		name    string
+		rows    []in
+		maxGap  int
+		want    []want
+		added   int
+		refused int
+	}{
+		{
+			name:   "observed days untouched",
+			rows:   []in{{"2024-01-01", 10, 5}, {"2024-01-02", 11, 6}},
+			maxGap: 3,
+			want: []want{
+				{"2024-01-01", 10, 5, ""},
+				{"2024-01-02", 11, 6, "price_change_24h:derived;price_change_pct_24h:derived"},
+			},
+		},
+		{
+			name:   "missing day added as a copy of the day before",
+			rows:   []in{{"2024-01-01", 10, 5}, {"2024-01-03", 12, 7}},
+			maxGap: 3,
+			want: []want{
+				{"2024-01-01", 10, 5, ""},
+				{"2024-01-02", 10, 5, "price:ffill;volume_24h:ffill;price_change_24h:derived;price_change_pct_24h:derived"},
+				{"2024-01-03", 12, 7, "price_change_24h:derived;price_change_pct_24h:derived"},
+			},
+			added: 1,
+		},
+		{
+			name:   "gap over the limit left open",
+			rows:   []in{{"2024-01-01", 10, 5}, {"2024-01-06", 12, 7}},
+			maxGap: 3,
+			want: []want{
+				{"2024-01-01", 10, 5, ""},
+				{"2024-01-06", 12, 7, ""},
+			},
+			refused: 1,
+		},
+		{
+			name:   "ffill within the limit, bfill before the first value",
+			rows:   []in{{"2024-01-01", 10, nan}, {"2024-01-02", 10, 5}, {"2024-01-03", 10, nan}},
+			maxGap: 3,
+			want: []want{
+				{"2024-01-01", 10, 5, "volume_24h:bfill"},
+				{"2024-01-02", 10, 5, "price_change_24h:derived;price_change_pct_24h:derived"},
+				{"2024-01-03", 10, 5, "volume_24h:ffill;price_change_24h:derived;price_change_pct_24h:derived"},
+			},
+		},
+		{
+			name:   "value too old to carry forward",
+			rows:   []in{{"2024-01-01", 10, 5}, {"2024-01-02", 10, nan}, {"2024-01-03", 10, nan}},
+			maxGap: 1,
+			want: []want{
+				{"2024-01-01", 10, 5, ""},
+				{"2024-01-02", 10, 5, "volume_24h:ffill;price_change_24h:derived;price_change_pct_24h:derived"},
+				{"2024-01-03", 10, nan, "price_change_24h:derived;price_change_pct_24h:derived"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var rows []UnifiedRow
+		for _, r := range tt.rows {
+			row := newUnifiedRow()
+			row.TokenID, row.Date, row.Price, row.Volume24h = "bitcoin", r.date, r.price, r.volume
+			rows = append(rows, row)
+		}
+
+		got, stats := applyImputeStage(rows, ImputeConfig{Enabled: true, Columns: []string{"volume_24h"}, MaxGap: tt.maxGap})
+		if stats.RowsAdded != tt.added || len(stats.Refused) != tt.refused {
+			t.Errorf("%s: added %d, refused %v; want %d added, %d refused", tt.name, stats.RowsAdded, stats.Refused, tt.added, tt.refused)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d rows, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			r := got[i]
+			if r.Date != w.date || r.Price != w.price || !sameFloat(r.Volume24h, w.volume) || r.ImputedFields != w.fields || r.Imputed != (w.fields != "") {
+				t.Errorf("%s: row %d = %s price=%v volume=%v %q, want %s price=%v volume=%v %q",
+					tt.name, i, r.Date, r.Price, r.Volume24h, r.ImputedFields, w.date, w.price, w.volume, w.fields)
+			}
+		}
+	}
+}
+
+func TestApplyImputeStageKeepsTokensApart(t *testing.T) {
+	var rows []UnifiedRow
+	for _, r := range []struct{ token, date string }{
+		{"a", "2024-01-01"}, {"a", "2024-01-02"}, {"b", "2024-01-05"}, {"b", "2024-01-06"},
+	} {
+		row := newUnifiedRow()
+		row.TokenID, row.Date, row.Price = r.token, r.date, 1
+		rows = append(rows, row)
+	}
+	got, stats := applyImputeStage(rows, ImputeConfig{Enabled: true, Columns: []string{"volume_24h"}, MaxGap: 3})
+	var dates []string
+	for _, r := range got {
+		dates = append(dates, r.TokenID+" "+r.Date)
+	}
+	want := []string{"a 2024-01-01", "a 2024-01-02", "b 2024-01-05", "b 2024-01-06"}
+	if !slices.Equal(dates, want) || stats.RowsAdded != 0 {
+		t.Errorf("rows = %v (%d added), want %v", dates, stats.RowsAdded, want)
+	}
+}
+
+// sameFloat treats two missing values as equal
+func sameFloat(a, b float64) bool {
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
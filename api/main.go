@@ -1,15 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"github.com/joho/godotenv"
 )
@@ -25,52 +24,45 @@ var (
 	CMC_CSV_PATH = "./data/cmc_data_02.csv"
 	LOG_PATH     = "./data/api_scraper.log"
 
-	// Tokens to track (CoinGecko IDs)
-	TOKENS = []string{
-		"bitcoin", "ethereum", "solana", "cardano", "ripple",
-		"polkadot", "dogecoin", "avalanche-2", "chainlink", "polygon",
-		"uniswap", "litecoin", "stellar", "cosmos", "monero",
-		"tron", "ethereum-classic", "filecoin", "hedera-hashgraph", "aptos",
-	}
+	// Storage backend: csv (files above) or sqlite (see store.go)
+	STORE_BACKEND = envOr("STORE_BACKEND", STORE_CSV)
+	SQLITE_PATH   = envOr("SQLITE_PATH", "./data/market_data.db")
+	STORE         Store
+
+	// Backfill checkpoints (per token, per source)
+	RUN_STATE_PATH = "./data/run_state.json"
+
+	// Token registry shared with the scraper
+	TOKEN_REGISTRY_PATH = envOr("TOKEN_REGISTRY_PATH", "../tokens.json")
+	TOKEN_REGISTRY      *TokenRegistry
+
+	// Resolved symbols, names and ids (see metadata.go), refreshed weekly
+	METADATA_PATH = envOr("METADATA_CACHE_PATH", "../token_metadata.json")
+	METADATA_TTL  = 7 * 24 * time.Hour
+	METADATA      *MetadataCache
 
-	// Rate limiting (calls per minute)
-	CG_RATE_LIMIT  = 10              // CoinGecko free tier: 10-15 calls/min
-	CMC_RATE_LIMIT = 30              // CMC free tier: 30 calls/min
-	CG_DELAY       = 7 * time.Second // 60s / 10 calls = 6s, using 7s to be safe
-	CMC_DELAY      = 3 * time.Second // 60s / 30 calls = 2s, using 3s to be safe
+	// Tokens to track, loaded from the registry
+	TOKENS []TokenEntry
+
+	// Rate limiting (see RATE_PLANS in ratelimit.go)
+	COINGECKO_PLAN = os.Getenv("COINGECKO_PLAN") // free, demo or pro (default: demo if a key is set, else free)
+	CMC_PLAN       = "cmc_basic"                 // CMC free tier: 30 calls/min
+	MAX_RETRIES    = 5                           // Retries per request on 429/5xx
+
+	CG_LIMITER  *RateLimiter
+	CMC_LIMITER *RateLimiter
 
 	// Historical data range (CoinGecko supports up to 365 days on free tier)
 	DAYS_HISTORICAL = 365
 
-	// Run mode: set to true to only collect current snapshots (for repeated runs)
-	SKIP_HISTORICAL = false // Set to true after first run
-)
+	// Run mode: set SKIP_HISTORICAL=true to only collect current snapshots.
+	// Otherwise missing history is resumed from the run state on every run.
+	SKIP_HISTORICAL = os.Getenv("SKIP_HISTORICAL") == "true"
 
-var TOKEN_METADATA = map[string]struct {
-	Symbol string
-	Name   string
-}{
-	"bitcoin":            {"btc", "Bitcoin"},
-	"ethereum":           {"eth", "Ethereum"},
-	"solana":             {"sol", "Solana"},
-	"cardano":            {"ada", "Cardano"},
-	"ripple":             {"xrp", "XRP"},
-	"polkadot":           {"dot", "Polkadot"},
-	"dogecoin":           {"doge", "Dogecoin"},
-	"avalanche-2":        {"avax", "Avalanche"},
-	"chainlink":          {"link", "Chainlink"},
-	"polygon":            {"matic", "Polygon"},
-	"uniswap":            {"uni", "Uniswap"},
-	"litecoin":           {"ltc", "Litecoin"},
-	"stellar":            {"xlm", "Stellar"},
-	"cosmos":             {"atom", "Cosmos"},
-	"monero":             {"xmr", "Monero"},
-	"tron":               {"trx", "TRON"},
-	"ethereum-classic":   {"etc", "Ethereum Classic"},
-	"filecoin":           {"fil", "Filecoin"},
-	"hedera-hashgraph":   {"hbar", "Hedera"},
-	"aptos":              {"apt", "Aptos"},
-}
+	// Rows that fail the data quality checks (see quality.go)
+	QUARANTINE_PATH = envOr("QUARANTINE_PATH", "./data/quarantine.csv")
+	QUARANTINE      *Quarantine
+)
 
 // ===== DATA STRUCTURES =====
 type CoinGeckoHistoricalResponse struct {
@@ -132,17 +124,68 @@ type CMCStatus struct {
 // ===== MAIN =====
 func main() {
 	godotenv.Load();
-	startTime := time.Now()
 
-	// Setup logging
+	cmd := ""
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+
+	switch cmd {
+	case "":
+		runCollector()
+	case "validate":
+		os.Exit(runValidate())
+	case "gaps":
+		os.Exit(runGaps(os.Args[2:]))
+	case "normalize":
+		os.Exit(runNormalize(os.Args[2:]))
+	case "serve":
+		os.Exit(runServe(os.Args[2:]))
+	case "export":
+		os.Exit(runExport(os.Args[2:]))
+	case "metadata":
+		os.Exit(runMetadata())
+	case "reconcile":
+		os.Exit(runReconcile(os.Args[2:]))
+	case "migrate":
+		os.Exit(runMigrate(os.Args[2:]))
+	default:
+		fmt.Printf("Unknown command: %s\n", cmd)
+		fmt.Println("Usage: go run . [validate|gaps [--fill]|normalize [--out path] [--impute] [--outliers cap|flag]|serve|export --format parquet|metadata|reconcile [--threshold 0.02]|migrate [--dry-run]]")
+		os.Exit(2)
+	}
+}
+
+// setup opens the log file, loads the token registry and creates the
+// per-provider rate limiters. The caller closes the returned log file.
+func setup() *os.File {
 	os.MkdirAll("./data", 0755)
 	logFile, err := os.OpenFile(LOG_PATH, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("Failed to open log file: %v", err)
 	}
-	defer logFile.Close()
 	log.SetOutput(logFile)
 
+	TOKEN_REGISTRY, err = loadTokenRegistry(TOKEN_REGISTRY_PATH)
+	if err != nil {
+		log.Fatalf("Failed to load token registry: %v", err)
+	}
+	TOKENS = TOKEN_REGISTRY.Tokens
+	QUARANTINE = NewQuarantine(QUARANTINE_PATH)
+
+	CG_LIMITER = NewRateLimiter(RATE_PLANS["coingecko_"+coinGeckoPlan()], MAX_RETRIES)
+	CMC_LIMITER = NewRateLimiter(RATE_PLANS[CMC_PLAN], MAX_RETRIES)
+
+	return logFile
+}
+
+func runCollector() {
+	startTime := time.Now()
+	ctx := context.Background()
+
+	logFile := setup()
+	defer logFile.Close()
+
 	fmt.Println("╔════════════════════════════════════════════════════╗")
 	fmt.Println("║   CRYPTO API DATA COLLECTOR v3.0                  ║")
 	fmt.Println("╚════════════════════════════════════════════════════╝")
@@ -150,70 +193,64 @@ func main() {
 	fmt.Printf("📁 CoinGecko output: %s\n", CG_CSV_PATH)
 	fmt.Printf("📁 CoinMarketCap output: %s\n", CMC_CSV_PATH)
 
-	// Check if files exist (determines if this is first run)
 	cgExists := fileExists(CG_CSV_PATH)
 	cmcExists := fileExists(CMC_CSV_PATH)
 
-	if cgExists || cmcExists {
-		fmt.Println("\n✅ Existing data files detected")
-		fmt.Println("📝 Running in APPEND mode - only collecting current snapshots")
-		SKIP_HISTORICAL = true
+	STORE = openStore(cgExists, cmcExists)
+	defer STORE.Close()
+	state := openRunState(STORE)
+
+	pending := pendingBackfill(state, SOURCE_COINGECKO, TOKENS)
+	if SKIP_HISTORICAL {
+		fmt.Println("\n📝 SKIP_HISTORICAL set - only collecting current snapshots")
+	} else if len(pending) == 0 {
+		fmt.Println("\n✅ Historical backfill complete for all tokens")
+		fmt.Println("📝 Only collecting current snapshots")
 	} else {
-		fmt.Println("\n🆕 First run detected")
-		fmt.Println("📝 Running in FULL mode - collecting historical + current data")
+		fmt.Printf("\n🔁 Historical backfill pending for %d/%d tokens\n", len(pending), len(TOKENS))
 		fmt.Printf("📅 Historical days: %d\n", DAYS_HISTORICAL)
 	}
 
-	fmt.Printf("⏱️  Rate limits: CG=%ds, CMC=%ds\n\n", int(CG_DELAY.Seconds()), int(CMC_DELAY.Seconds()))
+	fmt.Printf("⏱️  Rate limits: CG=%.1fs, CMC=%.1fs (%s)\n\n",
+		CG_LIMITER.Interval().Seconds(), CMC_LIMITER.Interval().Seconds(), RATE_PLANS["coingecko_"+coinGeckoPlan()].Name)
 
-	// Check API keys
-	if CMC_API_KEY == "" {
-		fmt.Println("⚠️  WARNING: CMC_API_KEY not set. Set it as environment variable:")
-		fmt.Println("   export CMC_API_KEY='your-api-key-here'")
-		fmt.Println("   Get your key from: https://coinmarketcap.com/api/")
-	}
+	loadMetadata(ctx, true)
 
-	// Initialize CSV files if they don't exist
-	if !cgExists {
-		fmt.Println("🔧 Initializing CoinGecko CSV...")
-		if err := initCoinGeckoCSV(CG_CSV_PATH); err != nil {
-			log.Fatalf("Failed to init CoinGecko CSV: %v", err)
+	// Providers for the sources enabled in the registry (warns on missing keys)
+	fmt.Println()
+	registerProviders()
+
+	// Phase 1: Resume historical data for tokens that still miss some
+	for _, p := range PROVIDERS {
+		if SKIP_HISTORICAL || !p.Capabilities().Historical {
+			continue
 		}
-		fmt.Println("✅ CoinGecko CSV initialized")
-	}
 
-	if !cmcExists {
-		fmt.Println("🔧 Initializing CoinMarketCap CSV...")
-		if err := initCMCCSV(CMC_CSV_PATH); err != nil {
-			log.Fatalf("Failed to init CMC CSV: %v", err)
+		pending := pendingBackfill(state, p.Name(), TOKENS)
+		if len(pending) == 0 {
+			fmt.Printf("\n⏭️  Skipping %s historical data (nothing to backfill)\n", SOURCE_NAMES[p.Name()])
+		} else {
+			fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			fmt.Printf("📈 PHASE 1: %s Historical Data Collection\n", SOURCE_NAMES[p.Name()])
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			collectHistorical(ctx, p, state, pending)
 		}
-		fmt.Println("✅ CoinMarketCap CSV initialized")
-	}
 
-	// Phase 1: Collect CoinGecko Historical Data (only on first run)
-	if !SKIP_HISTORICAL {
-		fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("📈 PHASE 1: CoinGecko Historical Data Collection")
-		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		collectCoinGeckoHistorical()
-	} else {
-		fmt.Println("\n⏭️  Skipping historical data (already collected)")
+		// Phase 1b: Fill holes inside the history of tokens whose backfill is done
+		if p.Name() == SOURCE_COINGECKO {
+			collectCoinGeckoGaps(ctx, p, state)
+		}
 	}
 
-	// Phase 2: Collect CoinGecko Current Data (runs every time)
-	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("📊 PHASE 2: CoinGecko Current Market Data")
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	collectCoinGeckoCurrent()
-
-	// Phase 3: Collect CoinMarketCap Data (runs every time)
-	if CMC_API_KEY != "" {
+	// Phase 2: Collect current snapshots (runs every time)
+	for _, p := range PROVIDERS {
+		if !p.Capabilities().Quotes {
+			continue
+		}
 		fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		fmt.Println("💰 PHASE 3: CoinMarketCap Market Data")
+		fmt.Printf("📊 PHASE 2: %s Current Market Data\n", SOURCE_NAMES[p.Name()])
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-		collectCoinMarketCapData()
-	} else {
-		fmt.Println("\n⚠️  Skipping CoinMarketCap collection (API key not set)")
+		collectQuotes(ctx, p)
 	}
 
 	elapsed := time.Since(startTime)
@@ -221,229 +258,271 @@ func main() {
 	fmt.Println("║              COLLECTION COMPLETE ✅                ║")
 	fmt.Println("╚════════════════════════════════════════════════════╝")
 	fmt.Printf("⏱️  Total time: %v\n", elapsed.Round(time.Second))
+	fmt.Println("📉 API budget used this run:")
+	CG_LIMITER.Report()
+	CMC_LIMITER.Report()
+	QUARANTINE.Report()
 	fmt.Printf("📊 Data saved to:\n")
-	fmt.Printf("   - %s\n", CG_CSV_PATH)
-	fmt.Printf("   - %s\n", CMC_CSV_PATH)
+	if STORE_BACKEND == STORE_SQLITE {
+		fmt.Printf("   - %s\n", SQLITE_PATH)
+	} else {
+		fmt.Printf("   - %s\n", CG_CSV_PATH)
+		fmt.Printf("   - %s\n", CMC_CSV_PATH)
+	}
 
-	if SKIP_HISTORICAL {
-		fmt.Println("\n💡 Current snapshots added! Run again anytime to collect more data.")
-		fmt.Println("📈 Tip: Schedule this with cron for continuous data collection:")
-		fmt.Println("   */15 * * * * cd /path/to/api && go run main.go  # Every 15 minutes")
+	var remaining []string
+	for _, token := range pendingBackfill(state, SOURCE_COINGECKO, TOKENS) {
+		remaining = append(remaining, token.Key)
+	}
+	if len(remaining) > 0 && !SKIP_HISTORICAL && findProvider(SOURCE_COINGECKO) != nil {
+		fmt.Printf("\n⚠️  Historical backfill still incomplete for %d tokens: %s\n", len(remaining), strings.Join(remaining, ", "))
+		fmt.Printf("📈 Run again to resume them (progress is saved in %s).\n", RUN_STATE_PATH)
 	} else {
-		fmt.Println("\n💡 First collection complete! Historical data saved.")
-		fmt.Println("📈 Run again to append new current snapshots (historical won't re-collect).")
+		fmt.Println("\n💡 Current snapshots added! Run again anytime to collect more data.")
+		fmt.Println("📈 Tip: Run `go run . serve` for continuous data collection,")
+		fmt.Println("   or schedule this with cron:")
+		fmt.Println("   */15 * * * * cd /path/to/api && go run .  # Every 15 minutes")
 	}
 }
 
 // ===== UTILITY FUNCTIONS =====
-func fileExists(filepath string) bool {
-	info, err := os.Stat(filepath)
-	if os.IsNotExist(err) {
-		return false
+// openRunState loads backfill checkpoints. Installs that predate the run
+// state get their coverage seeded from the history already in the store.
+func openRunState(store Store) *RunState {
+	state, err := loadRunState(RUN_STATE_PATH)
+	if err != nil {
+		log.Fatalf("Failed to load run state: %v", err)
 	}
-	return !info.IsDir()
+	if !fileExists(RUN_STATE_PATH) {
+		if err := seedRunState(state, store); err != nil {
+			log.Printf("Could not seed run state from the %s store: %v", STORE_BACKEND, err)
+		}
+		if err := state.Save(RUN_STATE_PATH); err != nil {
+			log.Printf("Could not save run state: %v", err)
+		}
+	}
+	return state
 }
 
-// ===== COINGECKO HISTORICAL DATA =====
-func collectCoinGeckoHistorical() {
-	totalRecords := 0
-
-	for i, tokenID := range TOKENS {
-		fmt.Printf("\n[%d/%d] Collecting historical data for %s...\n", i+1, len(TOKENS), tokenID)
-
-		url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/market_chart?vs_currency=usd&days=%d&interval=daily",
-			tokenID, DAYS_HISTORICAL)
-
-		if COINGECKO_API_KEY != "" {
-			url += "&x_cg_demo_api_key=" + COINGECKO_API_KEY
+// initOutputs creates the CSV files that don't exist yet and upgrades an
+// existing one written in an older schema version (see schema.go). A file
+// in a version it does not know stops the run before any request is made.
+func initOutputs(cgExists, cmcExists bool) {
+	for _, f := range []struct {
+		path    string
+		dataset *Dataset
+		exists  bool
+	}{{CG_CSV_PATH, &CG_DATASET, cgExists}, {CMC_CSV_PATH, &CMC_DATASET, cmcExists}} {
+		if !f.exists {
+			continue
 		}
-
-		resp, err := http.Get(url)
+		from, err := ensureSchema(f.path, f.dataset)
 		if err != nil {
-			log.Printf("Error fetching %s: %v", tokenID, err)
-			fmt.Printf("  ❌ Error: %v\n", err)
-			time.Sleep(CG_DELAY)
-			continue
+			fmt.Printf("❌ %v\n", err)
+			log.Fatalf("Schema check failed: %v", err)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			log.Printf("API error for %s: Status %d, Body: %s", tokenID, resp.StatusCode, string(body))
-			fmt.Printf("  ❌ API Error: Status %d\n", resp.StatusCode)
-			time.Sleep(CG_DELAY)
-			continue
+		if current := f.dataset.Current().Version; from < current {
+			fmt.Printf("🔁 Upgraded %s from %s v%d to v%d (backup: %s.v%d.bak)\n",
+				f.path, f.dataset.Name, from, current, f.path, from)
 		}
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading body for %s: %v", tokenID, err)
-			fmt.Printf("  ❌ Error reading response\n")
-			time.Sleep(CG_DELAY)
-			continue
+	if !cgExists {
+		fmt.Println("🔧 Initializing CoinGecko CSV...")
+		if err := initCoinGeckoCSV(CG_CSV_PATH); err != nil {
+			log.Fatalf("Failed to init CoinGecko CSV: %v", err)
 		}
+		fmt.Println("✅ CoinGecko CSV initialized")
+	}
 
-		var data CoinGeckoHistoricalResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			log.Printf("Error parsing JSON for %s: %v", tokenID, err)
-			fmt.Printf("  ❌ Error parsing data\n")
-			time.Sleep(CG_DELAY)
-			continue
+	if !cmcExists {
+		fmt.Println("🔧 Initializing CoinMarketCap CSV...")
+		if err := initCMCCSV(CMC_CSV_PATH); err != nil {
+			log.Fatalf("Failed to init CMC CSV: %v", err)
 		}
+		fmt.Println("✅ CoinMarketCap CSV initialized")
+	}
+}
 
-		// Write to CSV
-		count := writeCoinGeckoHistoricalToCSV(tokenID, &data)
-		totalRecords += count
-		fmt.Printf("  ✅ Collected %d historical records\n", count)
+func fileExists(filepath string) bool {
+	info, err := os.Stat(filepath)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
 
-		// Rate limiting
-		fmt.Printf("  ⏳ Waiting %ds (rate limit)...\n", int(CG_DELAY.Seconds()))
-		time.Sleep(CG_DELAY)
+// shortError keeps console output to a status code instead of a full body
+func shortError(err error) string {
+	if apiErr, ok := err.(*APIError); ok {
+		return fmt.Sprintf("API status %d", apiErr.StatusCode)
 	}
+	return err.Error()
+}
 
-	fmt.Printf("\n📊 Total historical records collected: %d\n", totalRecords)
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
 }
 
-// ===== COINGECKO CURRENT DATA =====
-func collectCoinGeckoCurrent() {
-	// CoinGecko allows fetching multiple coins in one call (up to 250)
-	batchSize := 50 // Conservative batch size
+// ===== HISTORICAL BACKFILL =====
+func collectHistorical(ctx context.Context, p MarketDataProvider, state *RunState, tokens []TokenEntry) {
 	totalRecords := 0
+	want := historicalWindow(time.Now())
 
-	for i := 0; i < len(TOKENS); i += batchSize {
-		end := i + batchSize
-		if end > len(TOKENS) {
-			end = len(TOKENS)
+	for i, token := range tokens {
+		if ctx.Err() != nil {
+			fmt.Println("\n⏹️  Stopping historical backfill (shutting down)")
+			break
 		}
-		batch := TOKENS[i:end]
-
-		fmt.Printf("\n[Batch %d] Fetching current data for %d tokens...\n", (i/batchSize)+1, len(batch))
+		fmt.Printf("\n[%d/%d] Collecting historical data for %s...\n", i+1, len(tokens), token.Key)
 
-		ids := strings.Join(batch, ",")
-		url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/markets?vs_currency=usd&ids=%s&order=market_cap_desc&sparkline=false&price_change_percentage=1h,24h,7d", ids)
-
-		if COINGECKO_API_KEY != "" {
-			url += "&x_cg_demo_api_key=" + COINGECKO_API_KEY
+		st := state.Get(p.Name(), token.Key)
+		missing := st.MissingRanges(want)
+		if len(missing) == 0 {
+			fmt.Printf("  ⏭️  Already covered (%s..%s)\n", st.From, st.To)
+			continue
+		}
+		for _, r := range missing {
+			fmt.Printf("  📅 Missing %s (%d days)\n", r, r.Days())
 		}
 
-		resp, err := http.Get(url)
-		if err != nil {
-			log.Printf("Error fetching batch: %v", err)
-			fmt.Printf("  ❌ Error: %v\n", err)
-			time.Sleep(CG_DELAY)
-			continue
+		// One request per missing range, so days already on disk between
+		// them are not downloaded again
+		st.Attempts++
+		var err error
+		for _, r := range missing {
+			var points []HistoricalPoint
+			if points, err = p.FetchHistorical(ctx, token, r); err != nil {
+				break
+			}
+			count, first, last := writeHistorical(p.Name(), token, r, points)
+			totalRecords += count
+			st.Records += count
+			st.Extend(first, last)
+			fmt.Printf("  ✅ Collected %d historical records for %s\n", count, r)
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			log.Printf("API error: Status %d, Body: %s", resp.StatusCode, string(body))
-			fmt.Printf("  ❌ API Error: Status %d\n", resp.StatusCode)
-			time.Sleep(CG_DELAY)
-			continue
+		if err != nil {
+			log.Printf("Historical backfill failed for %s/%s: %v", p.Name(), token.Key, err)
+			fmt.Printf("  ❌ Error: %s\n", shortError(err))
+			st.LastError = err.Error()
+			st.Status = BACKFILL_FAILED
+			if st.From != "" {
+				st.Status = BACKFILL_PARTIAL
+			}
+		} else {
+			st.LastError = ""
+			st.Status = BACKFILL_PARTIAL
+			if len(st.MissingRanges(want)) == 0 {
+				st.Status = BACKFILL_COMPLETE
+			}
 		}
 
-		body, _ := io.ReadAll(resp.Body)
-		var data []CoinGeckoCurrentResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			log.Printf("Error parsing JSON: %v", err)
-			fmt.Printf("  ❌ Error parsing data\n")
-			time.Sleep(CG_DELAY)
-			continue
+		// Checkpoint after every token so an interrupted run loses nothing
+		st.touch()
+		if err := state.Save(RUN_STATE_PATH); err != nil {
+			log.Printf("Could not save run state: %v", err)
 		}
+	}
 
-		count := writeCoinGeckoCurrentToCSV(&data)
-		totalRecords += count
-		fmt.Printf("  ✅ Collected %d current market records\n", count)
+	fmt.Printf("\n📊 Total historical records collected: %d\n", totalRecords)
+}
 
-		fmt.Printf("  ⏳ Waiting %ds (rate limit)...\n", int(CG_DELAY.Seconds()))
-		time.Sleep(CG_DELAY)
+// collectCoinGeckoGaps runs the gap analyser over tokens with a complete
+// backfill; tokens still pending are left to the backfill phase
+func collectCoinGeckoGaps(ctx context.Context, p MarketDataProvider, state *RunState) {
+	var tokens []TokenEntry
+	for _, token := range TOKENS {
+		if state.Get(p.Name(), token.Key).Status == BACKFILL_COMPLETE {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return
 	}
 
-	fmt.Printf("\n📊 Total current records collected: %d\n", totalRecords)
-}
+	gaps, err := analyseCoinGeckoGaps(STORE, state, tokens, historicalWindow(time.Now()))
+	if err != nil {
+		log.Printf("Gap analysis failed: %v", err)
+		return
+	}
 
-// ===== COINMARKETCAP DATA =====
-func collectCoinMarketCapData() {
-	if CMC_API_KEY == "" {
+	missing := 0
+	for _, g := range gaps {
+		missing += g.MissingDays()
+	}
+	if missing == 0 {
 		return
 	}
 
-	// CMC uses symbols, not IDs like CoinGecko
-	symbols := []string{"BTC", "ETH", "SOL", "ADA", "XRP", "DOT", "DOGE", "AVAX", "LINK", "MATIC",
-		"UNI", "LTC", "XLM", "ATOM", "XMR", "TRX", "ETC", "FIL", "HBAR", "APT"}
+	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("🩹 PHASE 1b: CoinGecko Gap Fill (%d missing days)\n", missing)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fillCoinGeckoGaps(ctx, p, state, gaps)
+}
 
+// ===== CURRENT QUOTES =====
+func collectQuotes(ctx context.Context, p MarketDataProvider) {
+	batchSize := p.Capabilities().MaxBatch
+	if batchSize <= 0 {
+		batchSize = len(TOKENS)
+	}
 	totalRecords := 0
-	batchSize := 50 // CMC allows multiple symbols per call
 
-	for i := 0; i < len(symbols); i += batchSize {
-		end := i + batchSize
-		if end > len(symbols) {
-			end = len(symbols)
+	for i := 0; i < len(TOKENS); i += batchSize {
+		if ctx.Err() != nil {
+			break
 		}
-		batch := symbols[i:end]
-
-		fmt.Printf("\n[Batch %d] Fetching CMC data for %d tokens...\n", (i/batchSize)+1, len(batch))
-
-		symbolStr := strings.Join(batch, ",")
-		url := fmt.Sprintf("https://pro-api.coinmarketcap.com/v1/cryptocurrency/quotes/latest?symbol=%s&convert=USD", symbolStr)
-
-		client := &http.Client{}
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			log.Printf("Error creating request: %v", err)
-			fmt.Printf("  ❌ Error: %v\n", err)
-			continue
+		end := i + batchSize
+		if end > len(TOKENS) {
+			end = len(TOKENS)
 		}
+		batch := TOKENS[i:end]
 
-		req.Header.Set("X-CMC_PRO_API_KEY", CMC_API_KEY)
-		req.Header.Set("Accept", "application/json")
+		fmt.Printf("\n[Batch %d] Fetching %s data for %d tokens...\n", (i/batchSize)+1, SOURCE_NAMES[p.Name()], len(batch))
 
-		resp, err := client.Do(req)
+		quotes, err := p.FetchQuotes(ctx, batch)
 		if err != nil {
-			log.Printf("Error making request: %v", err)
-			fmt.Printf("  ❌ Error: %v\n", err)
-			time.Sleep(CMC_DELAY)
+			log.Printf("%s quotes failed: %v", p.Name(), err)
+			fmt.Printf("  ❌ Error: %s\n", shortError(err))
 			continue
 		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
 
-		if resp.StatusCode != 200 {
-			log.Printf("CMC API error: Status %d, Body: %s", resp.StatusCode, string(body))
-			fmt.Printf("  ❌ API Error: Status %d\n", resp.StatusCode)
-			time.Sleep(CMC_DELAY)
-			continue
-		}
+		count := writeQuotes(p.Name(), quotes)
+		totalRecords += count
+		fmt.Printf("  ✅ Collected %d current market records\n", count)
+	}
 
-		var data CMCQuoteResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			log.Printf("Error parsing CMC JSON: %v", err)
-			fmt.Printf("  ❌ Error parsing data\n")
-			time.Sleep(CMC_DELAY)
-			continue
-		}
+	fmt.Printf("\n📊 Total %s records collected: %d\n", SOURCE_NAMES[p.Name()], totalRecords)
+}
 
-		if data.Status.ErrorCode != 0 {
-			log.Printf("CMC API returned error: %s", data.Status.ErrorMessage)
-			fmt.Printf("  ❌ CMC Error: %s\n", data.Status.ErrorMessage)
-			time.Sleep(CMC_DELAY)
-			continue
-		}
+// ===== CSV WRITERS =====
+// STORE_MU serialises store writes, CSV and SQLite alike, so jobs running
+// side by side in `serve` never interleave rows, and so closeStore can wait
+// for the write in flight. Once the store is closed, writes are dropped.
+var (
+	STORE_MU     sync.Mutex
+	STORE_CLOSED bool
+)
 
-		count := writeCMCDataToCSV(&data)
-		totalRecords += count
-		fmt.Printf("  ✅ Collected %d CMC records\n", count)
+// closeStore closes STORE after the write in flight, if any. Jobs that
+// outlive the shutdown grace period find it closed and drop their rows.
+func closeStore() {
+	STORE_MU.Lock()
+	defer STORE_MU.Unlock()
 
-		fmt.Printf("  ⏳ Waiting %ds (rate limit)...\n", int(CMC_DELAY.Seconds()))
-		time.Sleep(CMC_DELAY)
+	if STORE_CLOSED {
+		return
+	}
+	STORE_CLOSED = true
+	if err := STORE.Close(); err != nil {
+		log.Printf("Closing store: %v", err)
 	}
-
-	fmt.Printf("\n📊 Total CMC records collected: %d\n", totalRecords)
 }
 
-// ===== CSV WRITERS =====
 func initCoinGeckoCSV(filepath string) error {
 	file, err := os.Create(filepath)
 	if err != nil {
@@ -454,14 +533,7 @@ func initCoinGeckoCSV(filepath string) error {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	headers := []string{
-		"timestamp", "date", "token_id", "symbol", "name",
-		"price", "market_cap", "total_volume",
-		"high_24h", "low_24h", "price_change_24h", "price_change_percentage_24h",
-		"circulating_supply", "total_supply", "ath", "ath_date", "source",
-	}
-
-	return writer.Write(headers)
+	return writer.Write(CG_DATASET.Current().Columns)
 }
 
 func initCMCCSV(filepath string) error {
@@ -474,19 +546,77 @@ func initCMCCSV(filepath string) error {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	headers := []string{
-		"timestamp", "date", "symbol", "name", "slug",
-		"price", "volume_24h", "volume_change_24h",
-		"percent_change_1h", "percent_change_24h", "percent_change_7d",
-		"market_cap", "market_cap_dominance",
-		"circulating_supply", "total_supply", "max_supply",
-		"last_updated", "source",
+	return writer.Write(CMC_DATASET.Current().Columns)
+}
+
+// writeHistorical hands the points of a provider's history for r that pass
+// the quality checks to the store and returns how many rows were written
+// along with the first and last date
+func writeHistorical(source string, token TokenEntry, r DateRange, points []HistoricalPoint) (int, string, string) {
+	STORE_MU.Lock()
+	defer STORE_MU.Unlock()
+
+	if STORE_CLOSED {
+		log.Printf("Store closed, dropping %d %s points for %s", len(points), source, token.Key)
+		return 0, "", ""
+	}
+	points = QUARANTINE.validPoints(source, token, r, points)
+	if len(points) == 0 {
+		return 0, "", ""
+	}
+
+	count, err := STORE.WriteHistorical(source, token, points)
+	if err != nil {
+		log.Printf("Error storing %s history for %s: %v", source, token.Key, err)
+		return 0, "", ""
+	}
+
+	first, last := "", ""
+	for _, pt := range points {
+		if first == "" || pt.Date < first {
+			first = pt.Date
+		}
+		if pt.Date > last {
+			last = pt.Date
+		}
 	}
+	return count, first, last
+}
 
-	return writer.Write(headers)
+// writeQuotes hands a provider's snapshots to the store, with symbol, name
+// and CMC id taken from the resolver
+func writeQuotes(source string, quotes []Quote) int {
+	STORE_MU.Lock()
+	defer STORE_MU.Unlock()
+
+	if STORE_CLOSED {
+		log.Printf("Store closed, dropping %d %s quotes", len(quotes), source)
+		return 0
+	}
+	for i := range quotes {
+		token, ok := TOKEN_REGISTRY.Find(quotes[i].TokenKey)
+		if !ok {
+			continue
+		}
+		meta := METADATA.Resolve(token)
+		quotes[i].Symbol = meta.Symbol
+		quotes[i].Name = meta.Name
+		quotes[i].CMCID = meta.CMCID
+	}
+
+	quotes = QUARANTINE.validQuotes(source, quotes)
+	if len(quotes) == 0 {
+		return 0
+	}
+	count, err := STORE.WriteQuotes(source, quotes)
+	if err != nil {
+		log.Printf("Error storing %s quotes: %v", source, err)
+		return 0
+	}
+	return count
 }
 
-func writeCoinGeckoHistoricalToCSV(tokenID string, data *CoinGeckoHistoricalResponse) int {
+func writeCoinGeckoHistoricalToCSV(token TokenEntry, points []HistoricalPoint) int {
 	file, err := os.OpenFile(CG_CSV_PATH, os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		log.Printf("Error opening CSV: %v", err)
@@ -497,31 +627,23 @@ func writeCoinGeckoHistoricalToCSV(tokenID string, data *CoinGeckoHistoricalResp
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	count := 0
-	for i := 0; i < len(data.Prices); i++ {
-		timestamp := int64(data.Prices[i][0] / 1000)
-		date := time.Unix(timestamp, 0).Format("2006-01-02")
-		price := data.Prices[i][1]
-
-		var marketCap, volume float64
-		if i < len(data.MarketCaps) {
-			marketCap = data.MarketCaps[i][1]
-		}
-		if i < len(data.TotalVolumes) {
-			volume = data.TotalVolumes[i][1]
-		}
+	// The historical API has no symbol or name, they come from the resolver
+	meta := METADATA.Resolve(token)
 
+	count := 0
+	for _, pt := range points {
 		record := []string{
-			strconv.FormatInt(timestamp, 10),
-			date,
-			tokenID,
-			"", // symbol (not in historical API)
-			"", // name (not in historical API)
-			fmt.Sprintf("%.8f", price),
-			fmt.Sprintf("%.2f", marketCap),
-			fmt.Sprintf("%.2f", volume),
+			strconv.FormatInt(pt.Timestamp, 10),
+			pt.Date,
+			token.CoinGeckoID,
+			meta.Symbol,
+			meta.Name,
+			fmt.Sprintf("%.8f", pt.Price),
+			fmt.Sprintf("%.2f", pt.MarketCap),
+			fmt.Sprintf("%.2f", pt.Volume),
 			"", "", "", "", "", "", "", "", // empty fields for current data
 			"coingecko_historical",
+			CG_DATASET.versionField(),
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -534,7 +656,7 @@ func writeCoinGeckoHistoricalToCSV(tokenID string, data *CoinGeckoHistoricalResp
 	return count
 }
 
-func writeCoinGeckoCurrentToCSV(data *[]CoinGeckoCurrentResponse) int {
+func writeCoinGeckoCurrentToCSV(quotes []Quote) int {
 	file, err := os.OpenFile(CG_CSV_PATH, os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		log.Printf("Error opening CSV: %v", err)
@@ -545,29 +667,27 @@ func writeCoinGeckoCurrentToCSV(data *[]CoinGeckoCurrentResponse) int {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	timestamp := time.Now().Unix()
-	date := time.Now().Format("2006-01-02")
 	count := 0
-
-	for _, coin := range *data {
+	for _, q := range quotes {
 		record := []string{
-			strconv.FormatInt(timestamp, 10),
-			date,
-			coin.ID,
-			coin.Symbol,
-			coin.Name,
-			fmt.Sprintf("%.8f", coin.CurrentPrice),
-			fmt.Sprintf("%.2f", coin.MarketCap),
-			fmt.Sprintf("%.2f", coin.TotalVolume),
-			fmt.Sprintf("%.8f", coin.High24h),
-			fmt.Sprintf("%.8f", coin.Low24h),
-			fmt.Sprintf("%.8f", coin.PriceChange24h),
-			fmt.Sprintf("%.4f", coin.PriceChangePercentage24h),
-			fmt.Sprintf("%.2f", coin.CirculatingSupply),
-			fmt.Sprintf("%.2f", coin.TotalSupply),
-			fmt.Sprintf("%.8f", coin.ATH),
-			coin.ATHDate,
+			strconv.FormatInt(q.Timestamp, 10),
+			time.Unix(q.Timestamp, 0).Format("2006-01-02"),
+			q.TokenID,
+			q.Symbol,
+			q.Name,
+			fmt.Sprintf("%.8f", q.Price),
+			fmt.Sprintf("%.2f", q.MarketCap),
+			fmt.Sprintf("%.2f", q.Volume24h),
+			fmt.Sprintf("%.8f", q.High24h),
+			fmt.Sprintf("%.8f", q.Low24h),
+			fmt.Sprintf("%.8f", q.PriceChange24h),
+			fmt.Sprintf("%.4f", q.PercentChange24h),
+			fmt.Sprintf("%.2f", q.CirculatingSupply),
+			fmt.Sprintf("%.2f", q.TotalSupply),
+			fmt.Sprintf("%.8f", q.ATH),
+			q.ATHDate,
 			"coingecko_current",
+			CG_DATASET.versionField(),
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -580,7 +700,7 @@ func writeCoinGeckoCurrentToCSV(data *[]CoinGeckoCurrentResponse) int {
 	return count
 }
 
-func writeCMCDataToCSV(data *CMCQuoteResponse) int {
+func writeCMCDataToCSV(quotes []Quote) int {
 	file, err := os.OpenFile(CMC_CSV_PATH, os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		log.Printf("Error opening CSV: %v", err)
@@ -591,32 +711,28 @@ func writeCMCDataToCSV(data *CMCQuoteResponse) int {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	timestamp := time.Now().Unix()
-	date := time.Now().Format("2006-01-02")
 	count := 0
-
-	for _, coin := range data.Data {
-		quote := coin.Quote["USD"]
-
+	for _, q := range quotes {
 		record := []string{
-			strconv.FormatInt(timestamp, 10),
-			date,
-			coin.Symbol,
-			coin.Name,
-			coin.Slug,
-			fmt.Sprintf("%.8f", quote.Price),
-			fmt.Sprintf("%.2f", quote.Volume24h),
-			fmt.Sprintf("%.4f", quote.VolumeChange24h),
-			fmt.Sprintf("%.4f", quote.PercentChange1h),
-			fmt.Sprintf("%.4f", quote.PercentChange24h),
-			fmt.Sprintf("%.4f", quote.PercentChange7d),
-			fmt.Sprintf("%.2f", quote.MarketCap),
-			fmt.Sprintf("%.4f", quote.MarketCapDominance),
-			fmt.Sprintf("%.2f", coin.CirculatingSupply),
-			fmt.Sprintf("%.2f", coin.TotalSupply),
-			fmt.Sprintf("%.2f", coin.MaxSupply),
-			quote.LastUpdated,
+			strconv.FormatInt(q.Timestamp, 10),
+			time.Unix(q.Timestamp, 0).Format("2006-01-02"),
+			q.Symbol,
+			q.Name,
+			q.Slug,
+			fmt.Sprintf("%.8f", q.Price),
+			fmt.Sprintf("%.2f", q.Volume24h),
+			fmt.Sprintf("%.4f", q.VolumeChange24h),
+			fmt.Sprintf("%.4f", q.PercentChange1h),
+			fmt.Sprintf("%.4f", q.PercentChange24h),
+			fmt.Sprintf("%.4f", q.PercentChange7d),
+			fmt.Sprintf("%.2f", q.MarketCap),
+			fmt.Sprintf("%.4f", q.MarketCapDominance),
+			fmt.Sprintf("%.2f", q.CirculatingSupply),
+			fmt.Sprintf("%.2f", q.TotalSupply),
+			fmt.Sprintf("%.2f", q.MaxSupply),
+			q.LastUpdated,
 			"coinmarketcap",
+			CMC_DATASET.versionField(),
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -627,4 +743,4 @@ func writeCMCDataToCSV(data *CMCQuoteResponse) int {
 	}
 
 	return count
-}
\ No newline at end of file
+}
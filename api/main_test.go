@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers every historical request with one point per day and
+// remembers the ranges it was asked for
+type fakeProvider struct {
+	requests []DateRange
+}
+
+func (p *fakeProvider) Name() string               { return SOURCE_COINGECKO }
+func (p *fakeProvider) Capabilities() Capabilities { return Capabilities{} }
+
+func (p *fakeProvider) FetchHistorical(ctx context.Context, token TokenEntry, r DateRange) ([]HistoricalPoint, error) {
+	p.requests = append(p.requests, r)
+	var points []HistoricalPoint
+	for day := r.From; !day.After(r.To); day = day.AddDate(0, 0, 1) {
+		points = append(points, HistoricalPoint{Timestamp: day.Unix(), Date: day.Format("2006-01-02"), Price: 1})
+	}
+	return points, nil
+}
+
+func (p *fakeProvider) FetchQuotes(ctx context.Context, tokens []TokenEntry) ([]Quote, error) {
+	return nil, nil
+}
+
+func TestCollectHistoricalRequestsOnlyMissingRanges(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSQLiteStore(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	STORE, QUARANTINE, RUN_STATE_PATH = store, NewQuarantine(filepath.Join(dir, "quarantine.csv")), filepath.Join(dir, "run_state.json")
+
+	// Covered in the middle of the window, missing at both ends
+	want := historicalWindow(time.Now())
+	state := &RunState{Backfill: make(map[string]map[string]*BackfillStatus)}
+	st := state.Get(SOURCE_COINGECKO, "eth")
+	st.Extend(want.From.AddDate(0, 0, 10).Format("2006-01-02"), want.To.AddDate(0, 0, -5).Format("2006-01-02"))
+
+	p := &fakeProvider{}
+	collectHistorical(context.Background(), p, state, []TokenEntry{{Key: "eth"}})
+
+	if len(p.requests) != 2 {
+		t.Fatalf("got requests %v, want one per missing range", p.requests)
+	}
+	wantRequests := []DateRange{
+		{From: want.From, To: want.From.AddDate(0, 0, 9)},
+		{From: want.To.AddDate(0, 0, -4), To: want.To},
+	}
+	for i, r := range wantRequests {
+		if p.requests[i].String() != r.String() {
+			t.Errorf("request %d = %s, want %s", i, p.requests[i], r)
+		}
+	}
+	if st.Status != BACKFILL_COMPLETE {
+		t.Errorf("status = %s, want %s", st.Status, BACKFILL_COMPLETE)
+	}
+}
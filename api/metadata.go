@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ===== TOKEN METADATA =====
+// Real symbols, names and numeric ids resolved from CMC's id map and
+// CoinGecko's coin list, cached in ../token_metadata.json (shared with the
+// scraper, which has no CMC key). The registry's own values are the fallback
+// whenever the cache has nothing for a token.
+//
+// Resolved symbols and names go into every output. The CMC id is stored in
+// the SQLite tables only; the CSV layouts carry no id column, so CSV readers
+// look it up here by coingecko_id or cmc_slug.
+
+type TokenMetadata struct {
+	Symbol      string `json:"symbol"`
+	Name        string `json:"name"`
+	CMCID       int    `json:"cmc_id,omitempty"`
+	CMCSlug     string `json:"cmc_slug,omitempty"`
+	CoinGeckoID string `json:"coingecko_id,omitempty"`
+}
+
+type MetadataCache struct {
+	UpdatedAt string                   `json:"updated_at"`
+	Tokens    map[string]TokenMetadata `json:"tokens"` // registry key -> metadata
+}
+
+func loadMetadataCache(path string) (*MetadataCache, error) {
+	cache := &MetadataCache{Tokens: make(map[string]TokenMetadata)}
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, cache); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cache.Tokens == nil {
+		cache.Tokens = make(map[string]TokenMetadata)
+	}
+	return cache, nil
+}
+
+// Save writes the cache atomically, like the run state
+func (c *MetadataCache) Save(path string) error {
+	c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	body, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".token_metadata-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Stale reports whether the cache is empty or older than ttl
+func (c *MetadataCache) Stale(ttl time.Duration) bool {
+	updated, err := time.Parse(time.RFC3339, c.UpdatedAt)
+	return err != nil || len(c.Tokens) == 0 || time.Since(updated) > ttl
+}
+
+// Resolve returns the token's metadata: cached values where known, registry
+// values otherwise
+func (c *MetadataCache) Resolve(token TokenEntry) TokenMetadata {
+	meta := TokenMetadata{
+		Symbol:      strings.ToUpper(token.Symbol),
+		Name:        token.Name,
+		CMCID:       token.CMC.ID,
+		CMCSlug:     token.CMC.Slug,
+		CoinGeckoID: token.CoinGeckoID,
+	}
+	if c == nil {
+		return meta
+	}
+
+	cached, ok := c.Tokens[token.Key]
+	if !ok {
+		return meta
+	}
+	if cached.Symbol != "" {
+		meta.Symbol = cached.Symbol
+	}
+	if cached.Name != "" {
+		meta.Name = cached.Name
+	}
+	if cached.CMCID != 0 {
+		meta.CMCID = cached.CMCID
+	}
+	if cached.CMCSlug != "" {
+		meta.CMCSlug = cached.CMCSlug
+	}
+	if cached.CoinGeckoID != "" {
+		meta.CoinGeckoID = cached.CoinGeckoID
+	}
+	return meta
+}
+
+// refreshMetadata rebuilds the cache from CoinGecko's coin list and, when a
+// CMC key is set, CMC's id map. Fresh upstream values replace cached ones;
+// a source that fails or is not queried keeps what it gave last time.
+func refreshMetadata(ctx context.Context, cache *MetadataCache, tokens []TokenEntry) error {
+	var errs []string
+	fresh := make(map[string]TokenMetadata)
+
+	cgDone := false
+	if TOKEN_REGISTRY.Enabled(SOURCE_COINGECKO) {
+		coins, err := NewCoinGeckoProvider(CG_LIMITER).FetchCoinList(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("coingecko: %v", err))
+		} else {
+			cgDone = true
+			byID := make(map[string]CoinGeckoCoin, len(coins))
+			for _, coin := range coins {
+				byID[coin.ID] = coin
+			}
+			for _, token := range tokens {
+				coin, ok := byID[token.CoinGeckoID]
+				if !ok {
+					continue
+				}
+				fresh[token.Key] = TokenMetadata{
+					Symbol:      strings.ToUpper(coin.Symbol),
+					Name:        coin.Name,
+					CoinGeckoID: coin.ID,
+				}
+			}
+		}
+	}
+
+	cmcDone := false
+	if TOKEN_REGISTRY.Enabled(SOURCE_CMC) && CMC_API_KEY != "" {
+		var symbols []string
+		for _, token := range tokens {
+			if token.CMC.Symbol != "" {
+				symbols = append(symbols, token.CMC.Symbol)
+			}
+		}
+		entries, err := NewCMCProvider(CMC_LIMITER).FetchIDMap(ctx, symbols)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("coinmarketcap: %v", err))
+		} else {
+			cmcDone = true
+			for _, token := range tokens {
+				entry, ok := matchCMCEntry(token, entries)
+				if !ok {
+					continue
+				}
+				// CMC's symbol and name win over CoinGecko's
+				meta := fresh[token.Key]
+				meta.Symbol = entry.Symbol
+				meta.Name = entry.Name
+				meta.CMCID = entry.ID
+				meta.CMCSlug = entry.Slug
+				fresh[token.Key] = meta
+			}
+		}
+	}
+
+	for key, old := range cache.Tokens {
+		meta := fresh[key]
+		if !cgDone && meta.CoinGeckoID == "" {
+			meta.CoinGeckoID = old.CoinGeckoID
+		}
+		if !cmcDone && old.CMCID != 0 {
+			meta.Symbol, meta.Name = old.Symbol, old.Name
+			meta.CMCID, meta.CMCSlug = old.CMCID, old.CMCSlug
+		}
+		if meta.Symbol == "" {
+			meta.Symbol = old.Symbol
+		}
+		if meta.Name == "" {
+			meta.Name = old.Name
+		}
+		fresh[key] = meta
+	}
+
+	cache.Tokens = fresh
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// matchCMCEntry picks the token's entry by id, then slug, then the best
+// ranked coin with its symbol
+func matchCMCEntry(token TokenEntry, entries []CMCMapEntry) (CMCMapEntry, bool) {
+	var best CMCMapEntry
+	found := false
+	for _, e := range entries {
+		if token.CMC.ID != 0 && e.ID == token.CMC.ID {
+			return e, true
+		}
+		if token.CMC.Slug != "" && e.Slug == token.CMC.Slug {
+			best, found = e, true
+			continue
+		}
+		if token.CMC.ID == 0 && token.CMC.Slug == "" && strings.EqualFold(e.Symbol, token.CMC.Symbol) {
+			if !found || (e.Rank > 0 && (best.Rank == 0 || e.Rank < best.Rank)) {
+				best, found = e, true
+			}
+		}
+	}
+	return best, found
+}
+
+// loadMetadata loads the cache into METADATA and, if refresh is set and the
+// cache is stale, rebuilds it first. Failures only cost accuracy: rows fall
+// back to the registry's symbols and names.
+func loadMetadata(ctx context.Context, refresh bool) {
+	cache, err := loadMetadataCache(METADATA_PATH)
+	if err != nil {
+		log.Printf("Could not load metadata cache: %v", err)
+		cache = &MetadataCache{Tokens: make(map[string]TokenMetadata)}
+	}
+	METADATA = cache
+
+	if !refresh || !cache.Stale(METADATA_TTL) {
+		return
+	}
+
+	fmt.Println("🏷️  Refreshing token metadata...")
+	if err := refreshMetadata(ctx, cache, TOKENS); err != nil {
+		log.Printf("Metadata refresh incomplete: %v", err)
+		fmt.Printf("⚠️  Metadata refresh incomplete: %s\n", err)
+	}
+	if len(cache.Tokens) == 0 {
+		return
+	}
+	if err := cache.Save(METADATA_PATH); err != nil {
+		log.Printf("Could not save metadata cache: %v", err)
+	}
+}
+
+// runMetadata implements the `metadata` command: refresh the cache and show
+// what every token resolves to
+func runMetadata() int {
+	logFile := setup()
+	defer logFile.Close()
+
+	METADATA_TTL = 0
+	loadMetadata(context.Background(), true)
+
+	fmt.Printf("\n%-20s %-8s %-24s %8s  %s\n", "TOKEN", "SYMBOL", "NAME", "CMC ID", "COINGECKO ID")
+	for _, token := range TOKENS {
+		meta := METADATA.Resolve(token)
+		fmt.Printf("%-20s %-8s %-24s %8d  %s\n", token.Key, meta.Symbol, meta.Name, meta.CMCID, meta.CoinGeckoID)
+	}
+	fmt.Printf("\n📁 Cache: %s\n", METADATA_PATH)
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+// ===== MIGRATE COMMAND =====
+// The collector upgrades the file it appends to on open (ensureSchema in
+// schema.go); `migrate` does the same for all of its CSVs at once, older
+// runs' files included, and can report without rewriting anything.
+
+// The collector's CSV files, current and older runs alike
+var SCHEMA_FILES = []struct {
+	Path    string
+	Dataset *Dataset
+}{
+	{"./data/cg_data_01.csv", &CG_DATASET},
+	{CG_CSV_PATH, &CG_DATASET},
+	{"./data/cmc_data_01.csv", &CMC_DATASET},
+	{CMC_CSV_PATH, &CMC_DATASET},
+}
+
+// runMigrate implements the `migrate` command
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "check the files and report without rewriting them")
+	fs.Parse(args)
+
+	logFile := setup()
+	defer logFile.Close()
+
+	fmt.Println("🗂️  CSV SCHEMA MIGRATION")
+	failed := 0
+	for _, f := range SCHEMA_FILES {
+		if !fileExists(f.Path) {
+			fmt.Printf("   ⏭️  %s: not collected yet\n", f.Path)
+			continue
+		}
+		from, to, rows, err := migrateCSV(f.Path, f.Dataset, *dryRun)
+		switch {
+		case err != nil:
+			log.Printf("Migrate: %s: %v", f.Path, err)
+			fmt.Printf("   ❌ %s: %v\n", f.Path, err)
+			failed++
+		case from == to:
+			fmt.Printf("   ✅ %s: already %s v%d\n", f.Path, f.Dataset.Name, to)
+		case *dryRun:
+			fmt.Printf("   🔎 %s: %s v%d -> v%d, %d rows (dry run)\n", f.Path, f.Dataset.Name, from, to, rows)
+		default:
+			fmt.Printf("   🔁 %s: %s v%d -> v%d, %d rows (backup: %s.v%d.bak)\n", f.Path, f.Dataset.Name, from, to, rows, f.Path, from)
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
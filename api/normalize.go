@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===== NORMALIZER =====
+// Go port of the notebook's harmonisation (Cell 3) and cleaning (Cell 4):
+// the five raw CSV schemas are mapped onto one standard schema, token ids are
+// resolved through the token registry, invalid prices are dropped and
+// duplicates on (token_id, date) are removed keeping the last row.
+
+const (
+	SCHEMA_COINGECKO   = "coingecko"
+	SCHEMA_CMC         = "coinmarketcap"
+	SCHEMA_CMC_SCRAPER = "cmc_scraper"
+)
+
+type UnifiedInput struct {
+	Path       string
+	Schema     string
+	DataSource string // value of the data_source column, as in the notebook
+}
+
+var (
+	UNIFIED_CSV_PATH = "./data/unified_data.csv"
+	SCRAPER_CSV_PATH = "../scraper/data/crypto_data_coinmarketcap.csv"
+	// CoinGecko page snapshots, in the collector's CoinGecko columns
+	CG_SCRAPER_CSV_PATH = "../scraper/data/crypto_data_coingecko_scraper.csv"
+
+	UNIFIED_INPUTS = []UnifiedInput{
+		{Path: "./data/cg_data_01.csv", Schema: SCHEMA_COINGECKO, DataSource: "coingecko_api_historical"},
+		{Path: "./data/cg_data_02.csv", Schema: SCHEMA_COINGECKO, DataSource: "coingecko_api_full"},
+		{Path: "./data/cmc_data_01.csv", Schema: SCHEMA_CMC, DataSource: "coinmarketcap_api_01"},
+		{Path: "./data/cmc_data_02.csv", Schema: SCHEMA_CMC, DataSource: "coinmarketcap_api_02"},
+		{Path: SCRAPER_CSV_PATH, Schema: SCHEMA_CMC_SCRAPER, DataSource: "coinmarketcap_scraper"},
+		{Path: CG_SCRAPER_CSV_PATH, Schema: SCHEMA_COINGECKO, DataSource: "coingecko_scraper"},
+	}
+)
+
+// The dataset each raw schema is a version of (schema.go)
+var SCHEMA_DATASETS = map[string]*Dataset{
+	SCHEMA_COINGECKO:   &CG_DATASET,
+	SCHEMA_CMC:         &CMC_DATASET,
+	SCHEMA_CMC_SCRAPER: &CMC_SCRAPER_DATASET,
+}
+
+var UNIFIED_HEADERS = []string{
+	"timestamp", "date", "token_id", "token_symbol", "token_name",
+	"price", "open", "high", "low", "close",
+	"market_cap", "volume_24h", "high_24h", "low_24h",
+	"price_change_24h", "price_change_pct_24h", "percent_change_1h", "percent_change_7d",
+	"circulating_supply", "total_supply", "max_supply", "market_cap_dominance",
+	"ath", "ath_date", "data_source", "original_source",
+	"volatility_7d", "outlier_flags", "is_imputed", "imputed_fields",
+}
+
+// UnifiedRow is one record of the standard schema. Missing numbers are NaN
+// and written as empty cells.
+type UnifiedRow struct {
+	Timestamp          int64
+	Date               string
+	TokenID            string
+	TokenSymbol        string
+	TokenName          string
+	Price              float64
+	Open               float64
+	High               float64
+	Low                float64
+	Close              float64
+	MarketCap          float64
+	Volume24h          float64
+	High24h            float64
+	Low24h             float64
+	PriceChange24h     float64
+	PriceChangePct24h  float64
+	PercentChange1h    float64
+	PercentChange7d    float64
+	CirculatingSupply  float64
+	TotalSupply        float64
+	MaxSupply          float64
+	MarketCapDominance float64
+	ATH                float64
+	ATHDate            string
+	DataSource         string
+	OriginalSource     string
+	Volatility7d       float64 // filled by addVolatility (features.go)
+	OutlierFlags       string  // column:action for each value the stage touched
+	Imputed            bool    // set by the imputation stage (impute.go)
+	ImputedFields      string  // column:method for each value the stage filled
+}
+
+func newUnifiedRow() UnifiedRow {
+	nan := math.NaN()
+	return UnifiedRow{
+		Price: nan, Open: nan, High: nan, Low: nan, Close: nan,
+		MarketCap: nan, Volume24h: nan, High24h: nan, Low24h: nan,
+		PriceChange24h: nan, PriceChangePct24h: nan, PercentChange1h: nan, PercentChange7d: nan,
+		CirculatingSupply: nan, TotalSupply: nan, MaxSupply: nan, MarketCapDominance: nan,
+		ATH: nan, Volatility7d: nan,
+	}
+}
+
+func (r UnifiedRow) Record() []string {
+	return []string{
+		strconv.FormatInt(r.Timestamp, 10), r.Date, r.TokenID, r.TokenSymbol, r.TokenName,
+		formatFloat(r.Price, 8), formatFloat(r.Open, 8), formatFloat(r.High, 8), formatFloat(r.Low, 8), formatFloat(r.Close, 8),
+		formatFloat(r.MarketCap, 2), formatFloat(r.Volume24h, 2), formatFloat(r.High24h, 8), formatFloat(r.Low24h, 8),
+		formatFloat(r.PriceChange24h, 8), formatFloat(r.PriceChangePct24h, 4), formatFloat(r.PercentChange1h, 4), formatFloat(r.PercentChange7d, 4),
+		formatFloat(r.CirculatingSupply, 2), formatFloat(r.TotalSupply, 2), formatFloat(r.MaxSupply, 2), formatFloat(r.MarketCapDominance, 4),
+		formatFloat(r.ATH, 8), r.ATHDate, r.DataSource, r.OriginalSource,
+		formatFloat(r.Volatility7d, 8), r.OutlierFlags,
+		strconv.FormatBool(r.Imputed), r.ImputedFields,
+	}
+}
+
+// formatFloat writes NaN as an empty cell
+func formatFloat(v float64, prec int) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// parseFloat reads an empty or unparsable cell as NaN, like
+// pd.to_numeric(errors='coerce')
+func parseFloat(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+// csvTable gives access to a CSV's columns by header name
+type csvTable struct {
+	index map[string]int
+	rows  [][]string
+}
+
+// readCSVTable reads a file of dataset d in whichever version its header is
+// and returns its rows upgraded to the current version
+func readCSVTable(path string, d *Dataset) (*csvTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	version, err := d.identifyHeader(path, header)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &csvTable{index: make(map[string]int)}
+	for i, name := range d.Current().Columns {
+		t.index[name] = i
+	}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record, err = d.upgradeRow(version, record); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		t.rows = append(t.rows, record)
+	}
+	return t, nil
+}
+
+// get returns the named column of a row, or "" when absent
+func (t *csvTable) get(row []string, column string) string {
+	i, ok := t.index[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// normalizeInput maps one raw dataset onto the standard schema (Cell 3)
+func normalizeInput(in UnifiedInput, reg *TokenRegistry) ([]UnifiedRow, error) {
+	d, ok := SCHEMA_DATASETS[in.Schema]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q for %s", in.Schema, in.Path)
+	}
+	t, err := readCSVTable(in.Path, d)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]UnifiedRow, 0, len(t.rows))
+	for _, rec := range t.rows {
+		r := newUnifiedRow()
+		r.DataSource = in.DataSource
+		r.OriginalSource = t.get(rec, "source")
+
+		switch in.Schema {
+		case SCHEMA_COINGECKO:
+			r.Timestamp, _ = strconv.ParseInt(t.get(rec, "timestamp"), 10, 64)
+			r.Date = t.get(rec, "date")
+			r.TokenID = t.get(rec, "token_id")
+			r.TokenSymbol = strings.ToUpper(t.get(rec, "symbol"))
+			r.TokenName = t.get(rec, "name")
+			r.Price = parseFloat(t.get(rec, "price"))
+			r.MarketCap = parseFloat(t.get(rec, "market_cap"))
+			r.Volume24h = parseFloat(t.get(rec, "total_volume"))
+			r.High24h = parseFloat(t.get(rec, "high_24h"))
+			r.Low24h = parseFloat(t.get(rec, "low_24h"))
+			r.PriceChange24h = parseFloat(t.get(rec, "price_change_24h"))
+			r.PriceChangePct24h = parseFloat(t.get(rec, "price_change_percentage_24h"))
+			r.CirculatingSupply = parseFloat(t.get(rec, "circulating_supply"))
+			r.TotalSupply = parseFloat(t.get(rec, "total_supply"))
+			r.ATH = parseFloat(t.get(rec, "ath"))
+			r.ATHDate = t.get(rec, "ath_date")
+
+		case SCHEMA_CMC:
+			r.Timestamp, _ = strconv.ParseInt(t.get(rec, "timestamp"), 10, 64)
+			r.Date = t.get(rec, "date")
+			r.TokenSymbol = strings.ToUpper(t.get(rec, "symbol"))
+			r.TokenName = t.get(rec, "name")
+			if token, ok := reg.FindBySymbol(r.TokenSymbol); ok {
+				r.TokenID = token.CoinGeckoID
+			}
+			r.Price = parseFloat(t.get(rec, "price"))
+			r.MarketCap = parseFloat(t.get(rec, "market_cap"))
+			r.Volume24h = parseFloat(t.get(rec, "volume_24h"))
+			r.PriceChangePct24h = parseFloat(t.get(rec, "percent_change_24h"))
+			r.PercentChange1h = parseFloat(t.get(rec, "percent_change_1h"))
+			r.PercentChange7d = parseFloat(t.get(rec, "percent_change_7d"))
+			r.CirculatingSupply = parseFloat(t.get(rec, "circulating_supply"))
+			r.TotalSupply = parseFloat(t.get(rec, "total_supply"))
+			r.MaxSupply = parseFloat(t.get(rec, "max_supply"))
+			r.MarketCapDominance = parseFloat(t.get(rec, "market_cap_dominance"))
+
+		case SCHEMA_CMC_SCRAPER:
+			r.Date = t.get(rec, "date")
+			if d, err := time.Parse("2006-01-02", r.Date); err == nil {
+				r.Timestamp = d.Unix()
+			}
+			// Older scraper runs wrote the uppercased page slug as the symbol
+			r.TokenSymbol = strings.ToUpper(t.get(rec, "token_symbol"))
+			r.TokenName = t.get(rec, "token_name")
+			if token, ok := reg.FindBySymbol(r.TokenSymbol); ok {
+				r.TokenID = token.CoinGeckoID
+			} else if token, ok := reg.FindBySlug(strings.ToLower(r.TokenSymbol)); ok {
+				r.TokenID = token.CoinGeckoID
+				r.TokenSymbol = token.Symbol
+			}
+			r.Close = parseFloat(t.get(rec, "close"))
+			r.Price = r.Close // close is used as price
+			r.Open = parseFloat(t.get(rec, "open"))
+			r.High = parseFloat(t.get(rec, "high"))
+			r.Low = parseFloat(t.get(rec, "low"))
+			r.High24h = r.High
+			r.Low24h = r.Low
+			r.MarketCap = parseFloat(t.get(rec, "market_cap"))
+			r.Volume24h = parseFloat(t.get(rec, "volume"))
+
+		default:
+			return nil, fmt.Errorf("unknown schema %q for %s", in.Schema, in.Path)
+		}
+
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+type NormalizeStats struct {
+	Raw          int
+	NoTokenID    int
+	InvalidPrice int
+	Duplicates   int
+}
+
+// cleanUnified applies the Cell 4 cleaning steps and returns rows sorted by
+// token and timestamp
+func cleanUnified(rows []UnifiedRow, reg *TokenRegistry, stats *NormalizeStats) []UnifiedRow {
+	var kept []UnifiedRow
+	for _, r := range rows {
+		// Fill missing symbols and names from the token id
+		if token, ok := reg.FindByCoinGeckoID(r.TokenID); ok {
+			if r.TokenSymbol == "" {
+				r.TokenSymbol = token.Symbol
+			}
+			if r.TokenName == "" {
+				r.TokenName = token.Name
+			}
+		}
+		if r.TokenID == "" {
+			stats.NoTokenID++
+			continue
+		}
+		// Remove zero/negative prices
+		if !(r.Price > 0) {
+			stats.InvalidPrice++
+			continue
+		}
+		// Zero/negative volume and market cap become missing
+		if r.Volume24h <= 0 {
+			r.Volume24h = math.NaN()
+		}
+		if r.MarketCap <= 0 {
+			r.MarketCap = math.NaN()
+		}
+		kept = append(kept, r)
+	}
+
+	// Sort by token, time and source, then keep the last row per token/day
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := kept[i], kept[j]
+		if a.TokenID != b.TokenID {
+			return a.TokenID < b.TokenID
+		}
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return a.DataSource < b.DataSource
+	})
+
+	last := make(map[string]int)
+	for i, r := range kept {
+		last[r.TokenID+"|"+r.Date] = i
+	}
+	deduped := make([]UnifiedRow, 0, len(last))
+	for i, r := range kept {
+		if last[r.TokenID+"|"+r.Date] == i {
+			deduped = append(deduped, r)
+		}
+	}
+	stats.Duplicates = len(kept) - len(deduped)
+
+	return deduped
+}
+
+func writeUnifiedCSV(path string, rows []UnifiedRow) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(UNIFIED_HEADERS); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := writer.Write(r.Record()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// runNormalize implements the `normalize` command
+func runNormalize(args []string) int {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	out := fs.String("out", UNIFIED_CSV_PATH, "output CSV path")
+	imputeConfig := imputeFlags(fs)
+	outlierConfig := outlierFlags(fs)
+	fs.Parse(args)
+
+	impute, err := imputeConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	outliers, err := outlierConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+
+	logFile := setup()
+	defer logFile.Close()
+
+	fmt.Println("🔧 DATA STANDARDIZATION & HARMONIZATION")
+
+	var stats NormalizeStats
+	var all []UnifiedRow
+	for _, in := range UNIFIED_INPUTS {
+		rows, err := normalizeInput(in, TOKEN_REGISTRY)
+		if os.IsNotExist(err) {
+			fmt.Printf("   ⏭️  %s: not collected yet\n", in.DataSource)
+			continue
+		}
+		if err != nil {
+			log.Printf("Normalize: skipping %s: %v", in.Path, err)
+			fmt.Printf("   ❌ %s: %v\n", in.Path, err)
+			continue
+		}
+		fmt.Printf("   ✅ %s: %d records standardized\n", in.DataSource, len(rows))
+		all = append(all, rows...)
+	}
+	stats.Raw = len(all)
+
+	rows := cleanUnified(all, TOKEN_REGISTRY, &stats)
+
+	// Missing values are filled before outliers are looked for, as in the
+	// notebook (Cell 5 before Cell 9)
+	var imputed ImputeStats
+	if impute.Enabled {
+		rows, imputed = applyImputeStage(rows, impute)
+	}
+
+	addVolatility(rows)
+
+	var events []OutlierEvent
+	if outliers.Action != OUTLIER_OFF {
+		events = applyOutlierStage(rows, outliers)
+		if err := writeOutliersCSV(OUTLIERS_CSV_PATH, events); err != nil {
+			fmt.Printf("❌ Failed to write %s: %v\n", OUTLIERS_CSV_PATH, err)
+			return 1
+		}
+	}
+
+	if err := writeUnifiedCSV(*out, rows); err != nil {
+		fmt.Printf("❌ Failed to write %s: %v\n", *out, err)
+		return 1
+	}
+
+	tokens := make(map[string]bool)
+	for _, r := range rows {
+		tokens[r.TokenID] = true
+	}
+
+	fmt.Println("\n🧹 DATA CLEANING")
+	fmt.Printf("   Raw records: %d\n", stats.Raw)
+	fmt.Printf("   Removed %d records with an unknown token\n", stats.NoTokenID)
+	fmt.Printf("   Removed %d invalid price records\n", stats.InvalidPrice)
+	fmt.Printf("   Removed %d duplicate records\n", stats.Duplicates)
+	if impute.Enabled {
+		printImputeSummary(impute, imputed)
+	}
+	if outliers.Action != OUTLIER_OFF {
+		printOutlierSummary(outliers, len(rows), events)
+		fmt.Printf("   Details: %s\n", OUTLIERS_CSV_PATH)
+	}
+	fmt.Printf("\n✅ Unified dataset: %d records, %d tokens\n", len(rows), len(tokens))
+	fmt.Printf("📁 Saved to: %s\n", *out)
+	return 0
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testRegistry = &TokenRegistry{Tokens: []TokenEntry{
+	{Key: "eth", Name: "Ethereum", Symbol: "ETH", CoinGeckoID: "ethereum", ScraperSlug: "ethereum"},
+	{Key: "avax", Name: "Avalanche", Symbol: "AVAX", CoinGeckoID: "avalanche-2", ScraperSlug: "avalanche"},
+}}
+
+// rawCSV writes one row in the given columns, cells not in values empty
+func rawCSV(columns []string, values map[string]string) string {
+	row := make([]string, len(columns))
+	for i, col := range columns {
+		row[i] = values[col]
+	}
+	return strings.Join(columns, ",") + "\n" + strings.Join(row, ",") + "\n"
+}
+
+func TestNormalizeInput(t *testing.T) {
+	cg := map[string]string{
+		"timestamp": "1709251200", "date": "2024-03-01", "token_id": "ethereum", "symbol": "eth", "name": "Ethereum",
+		"price": "3400.5", "market_cap": "4e11", "total_volume": "1.5e10", "source": "coingecko",
+	}
+	cmc := map[string]string{
+		"timestamp": "1709251200", "date": "2024-03-01", "symbol": "AVAX", "name": "Avalanche", "slug": "avalanche",
+		"price": "41.2", "volume_24h": "6e8", "market_cap": "1.5e10", "source": "coinmarketcap",
+	}
+	scraped := map[string]string{
+		"date": "2024-03-01", "token_symbol": "ETH", "token_name": "Ethereum",
+		"open": "3300", "high": "3450", "low": "3280", "close": "3400.5", "volume": "1.5e10", "market_cap": "4e11",
+	}
+	legacySlug := maps.Clone(scraped)
+	legacySlug["token_symbol"] = "AVALANCHE"
+	noMarketCap := maps.Clone(cg)
+	noMarketCap["market_cap"] = ""
+
+	tests := []struct {
+		name        string
+		schema      string
+		csv         string
+		token       string
+		symbol      string
+		timestamp   int64
+		price       float64
+		volume      float64
+		marketCapOK bool
+	}{
+		{
+			name: "coingecko v1", schema: SCHEMA_COINGECKO, csv: rawCSV(CG_DATASET.Versions[0].Columns, cg),
+			token: "ethereum", symbol: "ETH", timestamp: 1709251200, price: 3400.5, volume: 1.5e10, marketCapOK: true,
+		},
+		{
+			name: "coingecko v2", schema: SCHEMA_COINGECKO, csv: rawCSV(CG_DATASET.Versions[1].Columns, noMarketCap),
+			token: "ethereum", symbol: "ETH", timestamp: 1709251200, price: 3400.5, volume: 1.5e10,
+		},
+		{
+			name: "coinmarketcap resolves the symbol", schema: SCHEMA_CMC, csv: rawCSV(CMC_DATASET.Versions[0].Columns, cmc),
+			token: "avalanche-2", symbol: "AVAX", timestamp: 1709251200, price: 41.2, volume: 6e8, marketCapOK: true,
+		},
+		{
+			name: "cmc scraper takes close as price", schema: SCHEMA_CMC_SCRAPER, csv: rawCSV(CMC_SCRAPER_DATASET.Current().Columns, scraped),
+			token: "ethereum", symbol: "ETH", timestamp: 1709251200, price: 3400.5, volume: 1.5e10, marketCapOK: true,
+		},
+		{
+			name: "cmc scraper slug as symbol", schema: SCHEMA_CMC_SCRAPER, csv: rawCSV(CMC_SCRAPER_DATASET.Current().Columns, legacySlug),
+			token: "avalanche-2", symbol: "AVAX", timestamp: 1709251200, price: 3400.5, volume: 1.5e10, marketCapOK: true,
+		},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "in.csv")
+		if err := os.WriteFile(path, []byte(tt.csv), 0644); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := normalizeInput(UnifiedInput{Path: path, Schema: tt.schema, DataSource: "test"}, testRegistry)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(rows) != 1 {
+			t.Errorf("%s: got %d rows, want 1", tt.name, len(rows))
+			continue
+		}
+		r := rows[0]
+		if r.TokenID != tt.token || r.TokenSymbol != tt.symbol || r.Timestamp != tt.timestamp ||
+			r.Price != tt.price || r.Volume24h != tt.volume || math.IsNaN(r.MarketCap) == tt.marketCapOK {
+			t.Errorf("%s: got token=%q symbol=%q ts=%d price=%v volume=%v market_cap=%v",
+				tt.name, r.TokenID, r.TokenSymbol, r.Timestamp, r.Price, r.Volume24h, r.MarketCap)
+		}
+	}
+}
+
+func TestNormalizeInputRefusesUnknownHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.csv")
+	if err := os.WriteFile(path, []byte("timestamp,price\n1709251200,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := normalizeInput(UnifiedInput{Path: path, Schema: SCHEMA_COINGECKO}, testRegistry); err == nil {
+		t.Error("read a file in no known version")
+	}
+}
+
+func TestCleanUnified(t *testing.T) {
+	row := func(token, date string, ts int64, source string, price float64) UnifiedRow {
+		r := newUnifiedRow()
+		r.TokenID, r.Date, r.Timestamp, r.DataSource, r.Price = token, date, ts, source, price
+		return r
+	}
+
+	// The notebook's Cell 4 sorts on (token_id, timestamp, data_source) with a
+	// stable sort and keeps the last row per (token_id, date)
+	tests := []struct {
+		name  string
+		rows  []UnifiedRow
+		want  []float64 // prices of the rows kept, in output order
+		stats NormalizeStats
+	}{
+		{
+			name: "later timestamp wins over source order",
+			rows: []UnifiedRow{
+				row("ethereum", "2024-03-01", 1709290000, "coingecko_api_full", 2),
+				row("ethereum", "2024-03-01", 1709251200, "coinmarketcap_scraper", 1),
+			},
+			want:  []float64{2},
+			stats: NormalizeStats{Duplicates: 1},
+		},
+		{
+			name: "same timestamp: last data_source alphabetically wins",
+			rows: []UnifiedRow{
+				row("ethereum", "2024-03-01", 1709251200, "coinmarketcap_scraper", 2),
+				row("ethereum", "2024-03-01", 1709251200, "coingecko_api_full", 1),
+			},
+			want:  []float64{2},
+			stats: NormalizeStats{Duplicates: 1},
+		},
+		{
+			name: "full tie: the later input row wins",
+			rows: []UnifiedRow{
+				row("ethereum", "2024-03-01", 1709251200, "coingecko_api_full", 1),
+				row("ethereum", "2024-03-01", 1709251200, "coingecko_api_full", 2),
+			},
+			want:  []float64{2},
+			stats: NormalizeStats{Duplicates: 1},
+		},
+		{
+			name: "sorted by token then time",
+			rows: []UnifiedRow{
+				row("ethereum", "2024-03-02", 1709337600, "coingecko_api_full", 4),
+				row("bitcoin", "2024-03-01", 1709251200, "coingecko_api_full", 1),
+				row("ethereum", "2024-03-01", 1709251200, "coingecko_api_full", 3),
+			},
+			want: []float64{1, 3, 4},
+		},
+		{
+			name: "bad prices and unknown tokens are dropped",
+			rows: []UnifiedRow{
+				row("ethereum", "2024-03-01", 1709251200, "a", 0),
+				row("ethereum", "2024-03-02", 1709337600, "a", -1),
+				row("ethereum", "2024-03-03", 1709424000, "a", math.NaN()),
+				row("", "2024-03-04", 1709510400, "a", 5),
+				row("ethereum", "2024-03-05", 1709596800, "a", 6),
+			},
+			want:  []float64{6},
+			stats: NormalizeStats{NoTokenID: 1, InvalidPrice: 3},
+		},
+	}
+	for _, tt := range tests {
+		var stats NormalizeStats
+		got := cleanUnified(tt.rows, testRegistry, &stats)
+		var prices []float64
+		for _, r := range got {
+			prices = append(prices, r.Price)
+		}
+		if !slices.Equal(prices, tt.want) || stats != tt.stats {
+			t.Errorf("%s: kept %v with %+v, want %v with %+v", tt.name, prices, stats, tt.want, tt.stats)
+		}
+	}
+}
+
+func TestCleanUnifiedFillsSymbolAndMissingVolume(t *testing.T) {
+	r := newUnifiedRow()
+	r.TokenID, r.Date, r.Price, r.Volume24h, r.MarketCap = "ethereum", "2024-03-01", 1, 0, -5
+	var stats NormalizeStats
+	got := cleanUnified([]UnifiedRow{r}, testRegistry, &stats)
+	if len(got) != 1 {
+		t.Fatalf("got %d rows, want 1", len(got))
+	}
+	if got[0].TokenSymbol != "ETH" || got[0].TokenName != "Ethereum" {
+		t.Errorf("symbol=%q name=%q, want ETH and Ethereum from the registry", got[0].TokenSymbol, got[0].TokenName)
+	}
+	if !math.IsNaN(got[0].Volume24h) || !math.IsNaN(got[0].MarketCap) {
+		t.Errorf("volume=%v market_cap=%v, want both missing", got[0].Volume24h, got[0].MarketCap)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ===== OUTLIER STAGE =====
+// The notebook's outlier rule (Cell 9) for the Go pipeline: values outside
+// [Q1 - k*IQR, Q3 + k*IQR] are capped (winsorised) or only flagged. Unlike
+// the notebook, which takes the quartiles over the whole dataset, they come
+// from each token's trailing window of rows, current row included, like
+// pandas' rolling(window, min_periods).quantile(), so a row's bounds never
+// depend on later data and a live pipeline feeding rows one at a time gets
+// the same result as a batch run. Every action is written to
+// OUTLIERS_CSV_PATH with the original value, and the row's outlier_flags
+// column names the fields touched.
+
+const (
+	OUTLIER_OFF  = "off"
+	OUTLIER_CAP  = "cap"
+	OUTLIER_FLAG = "flag"
+)
+
+var (
+	OUTLIERS_CSV_PATH = "./data/outliers.csv"
+
+	OUTLIER_WINDOW      = 30
+	OUTLIER_MIN_PERIODS = 7
+	OUTLIER_IQR_K       = 3.0
+	OUTLIER_COLUMNS     = "price,volume_24h,volatility_7d"
+)
+
+// OUTLIER_FIELDS are the columns the stage can check
+var OUTLIER_FIELDS = map[string]func(r *UnifiedRow) *float64{
+	"price":         func(r *UnifiedRow) *float64 { return &r.Price },
+	"volume_24h":    func(r *UnifiedRow) *float64 { return &r.Volume24h },
+	"market_cap":    func(r *UnifiedRow) *float64 { return &r.MarketCap },
+	"volatility_7d": func(r *UnifiedRow) *float64 { return &r.Volatility7d },
+}
+
+type OutlierConfig struct {
+	Action     string // cap, flag or off
+	Columns    []string
+	Window     int
+	MinPeriods int
+	K          float64
+}
+
+// outlierFlags registers the stage's options on a command's flag set
+func outlierFlags(fs *flag.FlagSet) func() (OutlierConfig, error) {
+	action := fs.String("outliers", OUTLIER_OFF, "outlier handling: cap (winsorise), flag or off")
+	columns := fs.String("outlier-columns", OUTLIER_COLUMNS, "comma-separated columns to check")
+	window := fs.Int("outlier-window", OUTLIER_WINDOW, "rows per token the quartiles are computed over")
+	minPeriods := fs.Int("outlier-min-periods", OUTLIER_MIN_PERIODS, "rows needed before a token's values are checked")
+	k := fs.Float64("outlier-k", OUTLIER_IQR_K, "IQR multiplier of the bounds")
+
+	return func() (OutlierConfig, error) {
+		cfg := OutlierConfig{Action: *action, Window: *window, MinPeriods: *minPeriods, K: *k}
+		switch cfg.Action {
+		case OUTLIER_OFF, OUTLIER_CAP, OUTLIER_FLAG:
+		default:
+			return cfg, fmt.Errorf("unknown --outliers %q (use cap, flag or off)", cfg.Action)
+		}
+		for _, col := range strings.Split(*columns, ",") {
+			col = strings.TrimSpace(col)
+			if _, ok := OUTLIER_FIELDS[col]; !ok {
+				return cfg, fmt.Errorf("unknown outlier column %q", col)
+			}
+			cfg.Columns = append(cfg.Columns, col)
+		}
+		if cfg.Window < 2 || cfg.MinPeriods < 1 || cfg.MinPeriods > cfg.Window {
+			return cfg, fmt.Errorf("need 2 <= window and 1 <= min periods <= window")
+		}
+		return cfg, nil
+	}
+}
+
+// OutlierEvent records one value outside its bounds
+type OutlierEvent struct {
+	TokenID string
+	Date    string
+	Column  string
+	Value   float64 // as it came in
+	Lower   float64
+	Upper   float64
+	Action  string // capped or flagged
+	Result  float64
+}
+
+// OutlierDetector runs the stage over rows fed in time order per token,
+// keeping the trailing window of each checked column per token
+type OutlierDetector struct {
+	cfg    OutlierConfig
+	tokens map[string]map[string][]float64 // token -> column -> window
+}
+
+func NewOutlierDetector(cfg OutlierConfig) *OutlierDetector {
+	return &OutlierDetector{cfg: cfg, tokens: make(map[string]map[string][]float64)}
+}
+
+// Observe checks the row's columns against the token's window and caps or
+// flags the values outside it. volatility_7d must already be filled
+// (addVolatility).
+func (d *OutlierDetector) Observe(r *UnifiedRow) []OutlierEvent {
+	windows, ok := d.tokens[r.TokenID]
+	if !ok {
+		windows = make(map[string][]float64)
+		d.tokens[r.TokenID] = windows
+	}
+
+	var events []OutlierEvent
+	var flags []string
+	for _, col := range d.cfg.Columns {
+		field := OUTLIER_FIELDS[col](r)
+		value := *field
+		windows[col] = pushWindow(windows[col], value, d.cfg.Window)
+		if math.IsNaN(value) {
+			continue
+		}
+
+		lower, upper, ok := iqrBounds(windows[col], d.cfg.MinPeriods, d.cfg.K)
+		if !ok || (value >= lower && value <= upper) {
+			continue
+		}
+
+		ev := OutlierEvent{
+			TokenID: r.TokenID, Date: r.Date, Column: col,
+			Value: value, Lower: lower, Upper: upper,
+			Action: "flagged", Result: value,
+		}
+		if d.cfg.Action == OUTLIER_CAP {
+			ev.Action = "capped"
+			ev.Result = math.Min(math.Max(value, lower), upper)
+			*field = ev.Result
+		}
+		events = append(events, ev)
+		flags = append(flags, col+":"+ev.Action)
+	}
+	r.OutlierFlags = strings.Join(flags, ";")
+	return events
+}
+
+// pushWindow appends v and drops the oldest value beyond size
+func pushWindow(window []float64, v float64, size int) []float64 {
+	window = append(window, v)
+	if len(window) > size {
+		window = window[len(window)-size:]
+	}
+	return window
+}
+
+// iqrBounds returns Q1 - k*IQR and Q3 + k*IQR over the window's non-missing
+// values, or false when there are fewer than minPeriods of them
+func iqrBounds(window []float64, minPeriods int, k float64) (float64, float64, bool) {
+	values := make([]float64, 0, len(window))
+	for _, v := range window {
+		if !math.IsNaN(v) {
+			values = append(values, v)
+		}
+	}
+	if len(values) < minPeriods {
+		return 0, 0, false
+	}
+	sort.Float64s(values)
+
+	q1, q3 := quantile(values, 0.25), quantile(values, 0.75)
+	iqr := q3 - q1
+	return q1 - k*iqr, q3 + k*iqr, true
+}
+
+// quantile of sorted values with linear interpolation (numpy and pandas'
+// default)
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// applyOutlierStage runs the detector over rows sorted by token and time
+func applyOutlierStage(rows []UnifiedRow, cfg OutlierConfig) []OutlierEvent {
+	d := NewOutlierDetector(cfg)
+	var events []OutlierEvent
+	for i := range rows {
+		events = append(events, d.Observe(&rows[i])...)
+	}
+	return events
+}
+
+func writeOutliersCSV(path string, events []OutlierEvent) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"token_id", "date", "column", "value", "lower", "upper", "action", "result"})
+	for _, ev := range events {
+		writer.Write([]string{
+			ev.TokenID, ev.Date, ev.Column,
+			formatFloat(ev.Value, 8), formatFloat(ev.Lower, 8), formatFloat(ev.Upper, 8),
+			ev.Action, formatFloat(ev.Result, 8),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// printOutlierSummary counts the stage's actions per column
+func printOutlierSummary(cfg OutlierConfig, rows int, events []OutlierEvent) {
+	perColumn := make(map[string]int)
+	for _, ev := range events {
+		perColumn[ev.Column]++
+	}
+	fmt.Printf("\n📐 OUTLIERS (%.0f×IQR over %d rows per token, %s)\n", cfg.K, cfg.Window, cfg.Action)
+	for _, col := range cfg.Columns {
+		fmt.Printf("   %-14s %5d (%.2f%%)\n", col, perColumn[col], float64(perColumn[col])/float64(max(rows, 1))*100)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0, 1},
+		{0.25, 1.75}, // numpy's linear interpolation
+		{0.5, 2.5},
+		{0.75, 3.25},
+		{1, 4},
+	}
+	for _, tt := range tests {
+		if got := quantile(sorted, tt.q); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestIQRBounds(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name         string
+		window       []float64
+		minPeriods   int
+		k            float64
+		lower, upper float64
+		ok           bool
+	}{
+		{"too few values", []float64{1, 2}, 3, 3, 0, 0, false},
+		{"missing values do not count", []float64{1, nan, 2, nan}, 3, 3, 0, 0, false},
+		{"k=1", []float64{4, 1, 3, 2}, 4, 1, 0.25, 4.75, true},
+		{"k=3 ignores NaN", []float64{1, 2, nan, 3, 4}, 4, 3, -2.75, 7.75, true},
+	}
+	for _, tt := range tests {
+		lower, upper, ok := iqrBounds(tt.window, tt.minPeriods, tt.k)
+		if ok != tt.ok || (ok && (math.Abs(lower-tt.lower) > 1e-12 || math.Abs(upper-tt.upper) > 1e-12)) {
+			t.Errorf("%s: got %v, %v, %v; want %v, %v, %v", tt.name, lower, upper, ok, tt.lower, tt.upper, tt.ok)
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	prices := []float64{10, 11, 10, 12, 11, 10, 11, 100, 11}
+	tests := []struct {
+		action    string
+		wantPrice float64 // of the 100 spike
+		wantFlags string
+	}{
+		{OUTLIER_CAP, 0, "price:capped"}, // capped to the upper bound, checked below
+		{OUTLIER_FLAG, 100, "price:flagged"},
+	}
+	for _, tt := range tests {
+		d := NewOutlierDetector(OutlierConfig{Action: tt.action, Columns: []string{"price"}, Window: 30, MinPeriods: 7, K: 3})
+
+		var events []OutlierEvent
+		rows := make([]UnifiedRow, len(prices))
+		for i, p := range prices {
+			rows[i] = newUnifiedRow()
+			rows[i].TokenID, rows[i].Price = "bitcoin", p
+			events = append(events, d.Observe(&rows[i])...)
+		}
+
+		if len(events) != 1 {
+			t.Fatalf("%s: %d events, want 1: %+v", tt.action, len(events), events)
+		}
+		ev, spike := events[0], rows[7]
+		if ev.Value != 100 || spike.OutlierFlags != tt.wantFlags {
+			t.Errorf("%s: event %+v, flags %q", tt.action, ev, spike.OutlierFlags)
+		}
+		want := tt.wantPrice
+		if tt.action == OUTLIER_CAP {
+			want = ev.Upper
+		}
+		if spike.Price != want || ev.Result != want {
+			t.Errorf("%s: spike price %v, result %v, want %v", tt.action, spike.Price, ev.Result, want)
+		}
+		// The bounds come from rows up to and including the spike only
+		if rows[8].OutlierFlags != "" {
+			t.Errorf("%s: row after the spike flagged %q", tt.action, rows[8].OutlierFlags)
+		}
+	}
+}
+
+func TestObserveKeepsTokensApart(t *testing.T) {
+	d := NewOutlierDetector(OutlierConfig{Action: OUTLIER_FLAG, Columns: []string{"price"}, Window: 30, MinPeriods: 3, K: 3})
+	for _, tok := range []struct {
+		id    string
+		price float64
+	}{{"a", 1}, {"a", 1}, {"a", 1}, {"b", 1000}, {"b", 1000}, {"b", 1000}} {
+		r := newUnifiedRow()
+		r.TokenID, r.Price = tok.id, tok.price
+		if ev := d.Observe(&r); len(ev) > 0 {
+			t.Errorf("%s at %v flagged against another token's window", tok.id, tok.price)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ===== MARKET DATA PROVIDERS =====
+// Every data source implements MarketDataProvider. The collector phases only
+// talk to PROVIDERS; HTTP, auth and response parsing live in the provider and
+// the CSV layout lives in the writers. Each provider has a BaseURL field so it
+// can be pointed at a local httptest server.
+
+var ErrNotSupported = errors.New("not supported by this provider")
+
+type Capabilities struct {
+	Historical bool // FetchHistorical returns daily history
+	Quotes     bool // FetchQuotes returns current snapshots
+	MaxBatch   int  // tokens per FetchQuotes call
+}
+
+// HistoricalPoint is one daily price/market cap/volume observation
+type HistoricalPoint struct {
+	Timestamp int64
+	Date      string
+	Price     float64
+	MarketCap float64
+	Volume    float64
+}
+
+// Quote is a current market snapshot. Providers leave fields they do not
+// report at zero.
+type Quote struct {
+	Timestamp          int64
+	TokenKey           string // registry key
+	CMCID              int    // numeric CoinMarketCap id, filled by the resolver
+	TokenID            string // provider's own id (CoinGecko id, CMC symbol)
+	Symbol             string
+	Name               string
+	Slug               string
+	Price              float64
+	MarketCap          float64
+	Volume24h          float64
+	VolumeChange24h    float64
+	High24h            float64
+	Low24h             float64
+	PriceChange24h     float64
+	PercentChange1h    float64
+	PercentChange24h   float64
+	PercentChange7d    float64
+	MarketCapDominance float64
+	CirculatingSupply  float64
+	TotalSupply        float64
+	MaxSupply          float64
+	ATH                float64
+	ATHDate            string
+	LastUpdated        string
+}
+
+type MarketDataProvider interface {
+	Name() string
+	Capabilities() Capabilities
+	FetchHistorical(ctx context.Context, token TokenEntry, r DateRange) ([]HistoricalPoint, error)
+	FetchQuotes(ctx context.Context, tokens []TokenEntry) ([]Quote, error)
+}
+
+// Display names for console output
+var SOURCE_NAMES = map[string]string{
+	SOURCE_COINGECKO:   "CoinGecko",
+	SOURCE_CMC:         "CoinMarketCap",
+	SOURCE_CMC_SCRAPER: "CoinMarketCap scraper",
+}
+
+var PROVIDERS []MarketDataProvider
+
+// registerProviders builds PROVIDERS from the sources enabled in the registry
+func registerProviders() {
+	PROVIDERS = nil
+
+	if TOKEN_REGISTRY.Enabled(SOURCE_COINGECKO) {
+		PROVIDERS = append(PROVIDERS, NewCoinGeckoProvider(CG_LIMITER))
+	} else {
+		fmt.Println("⏭️  CoinGecko disabled in token registry")
+	}
+
+	if !TOKEN_REGISTRY.Enabled(SOURCE_CMC) {
+		fmt.Println("⏭️  CoinMarketCap disabled in token registry")
+	} else if CMC_API_KEY == "" {
+		fmt.Println("⚠️  WARNING: CMC_API_KEY not set. Set it as environment variable:")
+		fmt.Println("   export CMC_API_KEY='your-api-key-here'")
+		fmt.Println("   Get your key from: https://coinmarketcap.com/api/")
+	} else {
+		PROVIDERS = append(PROVIDERS, NewCMCProvider(CMC_LIMITER))
+	}
+}
+
+// findProvider returns the registered provider for a source name
+func findProvider(name string) MarketDataProvider {
+	for _, p := range PROVIDERS {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testResponse is one answer of a canned upstream
+type testResponse struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+// cannedServer answers requests with responses in order, repeating the last
+func cannedServer(t *testing.T, responses []testResponse) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		resp := responses[min(n, len(responses))-1]
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+const (
+	cgMarketsBody = `[{"id":"ethereum","symbol":"eth","name":"Ethereum","current_price":2000.5,"market_cap":240000000000}]`
+	cmcQuotesBody = `{"status":{"error_code":0},"data":{"1027":{"id":1027,"name":"Ethereum","symbol":"ETH","slug":"ethereum",
+		"quote":{"USD":{"price":2000.5,"market_cap":240000000000}}}}}`
+)
+
+func newTestCoinGecko(baseURL string) MarketDataProvider {
+	return &CoinGeckoProvider{BaseURL: baseURL, limiter: testLimiter(1)}
+}
+
+func newTestCMC(baseURL string) MarketDataProvider {
+	return &CMCProvider{BaseURL: baseURL, limiter: testLimiter(1)}
+}
+
+func TestProviderQuotes(t *testing.T) {
+	token := TokenEntry{Key: "eth", CoinGeckoID: "ethereum", CMC: CMCMapping{ID: 1027}}
+	throttled := testResponse{status: http.StatusTooManyRequests, retryAfter: "0"}
+
+	tests := []struct {
+		name      string
+		provider  func(baseURL string) MarketDataProvider
+		responses []testResponse
+		wantErr   bool
+		wantCalls int32
+	}{
+		{"coingecko success", newTestCoinGecko, []testResponse{{200, "", cgMarketsBody}}, false, 1},
+		{"coingecko 429 then success", newTestCoinGecko, []testResponse{throttled, {200, "", cgMarketsBody}}, false, 2},
+		{"coingecko 429 every time", newTestCoinGecko, []testResponse{throttled}, true, 2},
+		{"coingecko malformed body", newTestCoinGecko, []testResponse{{200, "", `[{"id":`}}, true, 1},
+		{"cmc success", newTestCMC, []testResponse{{200, "", cmcQuotesBody}}, false, 1},
+		{"cmc 429 then success", newTestCMC, []testResponse{throttled, {200, "", cmcQuotesBody}}, false, 2},
+		{"cmc 429 every time", newTestCMC, []testResponse{throttled}, true, 2},
+		{"cmc malformed body", newTestCMC, []testResponse{{200, "", `{"data":[`}}, true, 1},
+		{"cmc error status", newTestCMC, []testResponse{{200, "", `{"status":{"error_code":1002,"error_message":"API key missing."}}`}}, true, 1},
+	}
+	for _, tt := range tests {
+		srv, calls := cannedServer(t, tt.responses)
+		quotes, err := tt.provider(srv.URL).FetchQuotes(context.Background(), []TokenEntry{token})
+		if n := calls.Load(); n != tt.wantCalls {
+			t.Errorf("%s: %d requests, want %d", tt.name, n, tt.wantCalls)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: want an error, got %+v", tt.name, quotes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(quotes) != 1 || quotes[0].TokenKey != "eth" || quotes[0].Price != 2000.5 || quotes[0].MarketCap != 240000000000 {
+			t.Errorf("%s: got %+v, want one eth quote at 2000.5", tt.name, quotes)
+		}
+	}
+}
+
+func TestCoinGeckoHistorical(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }
+	// Hourly points, as market_chart/range answers below 90 days
+	body := `{"prices":[[` + ms(day) + `,10],[` + ms(day.Add(time.Hour)) + `,11],[` +
+		ms(day.AddDate(0, 0, 1)) + `,12]],"market_caps":[],"total_volumes":[]}`
+
+	tests := []struct {
+		name      string
+		responses []testResponse
+		want      []float64
+		wantErr   bool
+	}{
+		{"success", []testResponse{{200, "", body}}, []float64{10, 12}, false},
+		{"429 then success", []testResponse{{429, "0", ""}, {200, "", body}}, []float64{10, 12}, false},
+		{"malformed body", []testResponse{{200, "", `{"prices":[[1,`}}, nil, true},
+	}
+	for _, tt := range tests {
+		srv, _ := cannedServer(t, tt.responses)
+		points, err := newTestCoinGecko(srv.URL).FetchHistorical(context.Background(),
+			TokenEntry{Key: "eth", CoinGeckoID: "ethereum"}, DateRange{From: day, To: day.AddDate(0, 0, 1)})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		var got []float64
+		for _, pt := range points {
+			got = append(got, pt.Price)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: prices %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
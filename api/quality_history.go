@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ===== HISTORY CHECKS =====
+// The collector's rules for daily history points; the shared rules and the
+// quarantine are in quality.go.
+
+// checkHistoricalPoint checks a daily observation requested for r
+func checkHistoricalPoint(pt HistoricalPoint, r DateRange) []Violation {
+	var v []Violation
+	if pt.Price <= 0 {
+		v = append(v, Violation{"non_positive_price", fmt.Sprintf("price=%g", pt.Price)})
+	}
+	if pt.Volume < 0 {
+		v = append(v, Violation{"negative_volume", fmt.Sprintf("volume=%g", pt.Volume)})
+	}
+	if pt.MarketCap < 0 {
+		v = append(v, Violation{"negative_market_cap", fmt.Sprintf("market_cap=%g", pt.MarketCap)})
+	}
+	if day, err := time.Parse("2006-01-02", pt.Date); err != nil {
+		v = append(v, Violation{"bad_date", fmt.Sprintf("date=%q", pt.Date)})
+	} else if !r.From.IsZero() && (day.Before(dayOf(r.From)) || day.After(dayOf(r.To))) {
+		v = append(v, Violation{"date_out_of_range", fmt.Sprintf("%s not in %s", pt.Date, r)})
+	}
+	return v
+}
+
+// validPoints returns the points that pass, quarantining the rest
+func (q *Quarantine) validPoints(source string, token TokenEntry, r DateRange, points []HistoricalPoint) []HistoricalPoint {
+	var valid []HistoricalPoint
+	for _, pt := range points {
+		if v := checkHistoricalPoint(pt, r); len(v) > 0 {
+			q.add(source, token.Key, pt.Date, v, pt)
+			continue
+		}
+		valid = append(valid, pt)
+	}
+	q.count(len(points))
+	return valid
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCheckQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote Quote
+		want  []string
+	}{
+		{"valid", Quote{Price: 1, High24h: 2, Low24h: 1, CirculatingSupply: 10, TotalSupply: 10}, nil},
+		{"no price", Quote{}, []string{"non_positive_price"}},
+		{"high below low", Quote{Price: 1, High24h: 1, Low24h: 2}, []string{"high_below_low"}},
+		{"supply within tolerance", Quote{Price: 1, CirculatingSupply: 1000.5, TotalSupply: 1000}, nil},
+		{"supply above total", Quote{Price: 1, CirculatingSupply: 1100, TotalSupply: 1000}, []string{"circulating_above_total"}},
+		{"negatives", Quote{Price: 1, Volume24h: -1, MarketCap: -1}, []string{"negative_volume", "negative_market_cap"}},
+		{"missing as NaN", Quote{Price: 1, High24h: math.NaN(), Low24h: 1, Volume24h: math.NaN(), TotalSupply: math.NaN(), CirculatingSupply: 10}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range checkQuote(tt.quote) {
+			got = append(got, v.Rule)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: rules = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHistoricalPointDateRange(t *testing.T) {
+	r := DateRange{
+		From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		date string
+		want []string
+	}{
+		{"2024-01-15", nil},
+		{"2023-12-31", []string{"date_out_of_range"}},
+		{"15/01/2024", []string{"bad_date"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range checkHistoricalPoint(HistoricalPoint{Date: tt.date, Price: 1}, r) {
+			got = append(got, v.Rule)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: rules = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestAppendQuarantineWritesHeaderOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.csv")
+	q := NewQuarantine(path)
+	q.validQuotes(SOURCE_COINGECKO, []Quote{{TokenKey: "a"}, {TokenKey: "b"}})
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "quarantined_at" || records[2][2] != "b" {
+		t.Errorf("quarantine file = %v", records)
+	}
+}
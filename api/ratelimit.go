@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ===== RATE LIMITING =====
+// One RateLimiter per provider. It spaces requests according to the plan's
+// per-minute limit, retries the same request on 429/5xx (honouring
+// Retry-After, otherwise exponential backoff with jitter), widens its spacing
+// after being throttled and keeps per-run counters for the budget report.
+
+type RatePlan struct {
+	Name         string
+	PerMinute    int
+	MonthlyQuota int // 0 = no published monthly cap
+}
+
+var RATE_PLANS = map[string]RatePlan{
+	"coingecko_free": {Name: "CoinGecko free", PerMinute: 10},
+	"coingecko_demo": {Name: "CoinGecko demo", PerMinute: 30, MonthlyQuota: 10000},
+	"coingecko_pro":  {Name: "CoinGecko pro", PerMinute: 500, MonthlyQuota: 500000},
+	"cmc_basic":      {Name: "CoinMarketCap basic", PerMinute: 30, MonthlyQuota: 10000},
+}
+
+// APIError is returned when a provider answers with a non-200 status after
+// all retries are used up
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+type LimiterStats struct {
+	Requests  int
+	Retries   int
+	Throttled int
+	Failures  int
+	Waited    time.Duration
+}
+
+type RateLimiter struct {
+	plan       RatePlan
+	baseGap    time.Duration // spacing derived from the plan
+	maxGap     time.Duration
+	maxRetries int
+	client     *http.Client
+
+	mu      sync.Mutex
+	gap     time.Duration // current spacing, widened after throttling
+	next    time.Time
+	started time.Time
+	stats   LimiterStats
+}
+
+func NewRateLimiter(plan RatePlan, maxRetries int) *RateLimiter {
+	// 10% headroom over the advertised limit
+	gap := time.Duration(float64(time.Minute) / float64(plan.PerMinute) * 1.1)
+	return &RateLimiter{
+		plan:       plan,
+		baseGap:    gap,
+		maxGap:     gap * 8,
+		gap:        gap,
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		started:    time.Now(),
+	}
+}
+
+// Interval returns the current spacing between requests
+func (l *RateLimiter) Interval() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gap
+}
+
+// Get performs a GET through the limiter and returns the body of the first
+// 200 response. Throttling and server errors are retried on the same URL;
+// waits end early when ctx is cancelled.
+func (l *RateLimiter) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= l.maxRetries; attempt++ {
+		if attempt > 0 {
+			l.count(func(s *LimiterStats) { s.Retries++ })
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		if err := l.wait(ctx); err != nil {
+			return nil, err
+		}
+		l.count(func(s *LimiterStats) { s.Requests++ })
+
+		resp, err := l.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			if attempt < l.maxRetries {
+				if err := l.sleep(ctx, l.backoff(attempt)); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			if attempt < l.maxRetries {
+				if err := l.sleep(ctx, l.backoff(attempt)); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			l.relax()
+			return body, nil
+		}
+
+		lastErr = &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			break
+		}
+		if attempt == l.maxRetries {
+			break
+		}
+
+		delay := l.backoff(attempt)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			l.count(func(s *LimiterStats) { s.Throttled++ })
+			l.widen()
+			if ra, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = ra
+			}
+		}
+		log.Printf("%s: status %d, retrying in %v (attempt %d/%d)", l.plan.Name, resp.StatusCode, delay.Round(time.Second), attempt+1, l.maxRetries)
+		fmt.Printf("  ⏳ %s returned %d, retrying in %ds...\n", l.plan.Name, resp.StatusCode, int(delay.Seconds()))
+
+		// A 429 throttles the provider for every caller sharing the limiter,
+		// so the pause moves the next free slot; the retry waits for it there
+		if resp.StatusCode == http.StatusTooManyRequests {
+			l.holdUntil(time.Now().Add(delay))
+			continue
+		}
+		if err := l.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	l.count(func(s *LimiterStats) { s.Failures++ })
+	return nil, lastErr
+}
+
+// wait blocks until the next request slot is free
+func (l *RateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.gap)
+	l.mu.Unlock()
+
+	return l.sleep(ctx, delay)
+}
+
+// holdUntil keeps all callers from sending before t
+func (l *RateLimiter) holdUntil(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.next) {
+		l.next = t
+	}
+}
+
+// sleep waits for d, or returns ctx's error if it is cancelled first
+func (l *RateLimiter) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	l.count(func(s *LimiterStats) { s.Waited += d })
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns base*2^attempt with full jitter on the upper half
+func (l *RateLimiter) backoff(attempt int) time.Duration {
+	d := l.baseGap << attempt
+	if d > 2*time.Minute || d <= 0 {
+		d = 2 * time.Minute
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// widen slows the limiter down after a 429
+func (l *RateLimiter) widen() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gap = l.gap * 3 / 2
+	if l.gap > l.maxGap {
+		l.gap = l.maxGap
+	}
+}
+
+// relax moves the spacing back towards the plan's rate after a success
+func (l *RateLimiter) relax() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gap -= (l.gap - l.baseGap) / 4
+}
+
+func (l *RateLimiter) count(f func(s *LimiterStats)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f(&l.stats)
+}
+
+func (l *RateLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// Report prints how much of the provider's budget this run consumed
+func (l *RateLimiter) Report() {
+	s := l.Stats()
+	elapsed := time.Since(l.started)
+
+	perMinute := 0.0
+	if elapsed > 0 {
+		perMinute = float64(s.Requests) / elapsed.Minutes()
+	}
+
+	fmt.Printf("   - %s: %d requests (%d retries, %d throttled, %d failed), waited %v\n",
+		l.plan.Name, s.Requests, s.Retries, s.Throttled, s.Failures, s.Waited.Round(time.Second))
+	fmt.Printf("     %.1f req/min of %d allowed", perMinute, l.plan.PerMinute)
+	if l.plan.MonthlyQuota > 0 {
+		fmt.Printf(", %.2f%% of monthly quota (%d)", float64(s.Requests)*100/float64(l.plan.MonthlyQuota), l.plan.MonthlyQuota)
+	}
+	fmt.Println()
+
+	log.Printf("Budget %s: requests=%d retries=%d throttled=%d failures=%d waited=%v",
+		l.plan.Name, s.Requests, s.Retries, s.Throttled, s.Failures, s.Waited.Round(time.Second))
+}
+
+// parseRetryAfter accepts both the delay-seconds and HTTP-date forms
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ===== PRICE RECONCILIATION =====
+// `reconcile` lines up the three price sources per token and day and
+// measures how far apart they are:
+//
+//	coingecko    CoinGecko history. Its point for a date is the first of that
+//	             day (~00:00 UTC), so it is counted as the previous day's close.
+//	cmc_api      CoinMarketCap API snapshots, the last one of the day. These
+//	             are intraday prices, so some spread against closes is normal.
+//	cmc_scraper  Daily closes from the CoinMarketCap history pages.
+//
+// With STORE_BACKEND=sqlite the sources are read from SQLITE_PATH instead of
+// the CSV files; the scraper's closes are there only if it shares the file.
+//
+// Each source is compared with the median of the sources present that day.
+// Days whose spread (max - min over the median) is above the threshold are
+// flagged, and the source furthest from the median is named, so the summary
+// shows which source tends to be the odd one out.
+
+const (
+	RECONCILE_COINGECKO   = "coingecko"
+	RECONCILE_CMC_API     = "cmc_api"
+	RECONCILE_CMC_SCRAPER = "cmc_scraper"
+)
+
+var (
+	RECONCILE_CSV_PATH  = "./data/reconcile_report.csv"
+	RECONCILE_THRESHOLD = 0.02 // 2% spread between sources
+
+	RECONCILE_SOURCES = []string{RECONCILE_COINGECKO, RECONCILE_CMC_API, RECONCILE_CMC_SCRAPER}
+)
+
+// ReconcileDay is one token and day with the price each source gave
+type ReconcileDay struct {
+	Date    string
+	TokenID string
+	Symbol  string
+	Prices  map[string]float64
+
+	Median    float64
+	Spread    float64            // (max - min) / median
+	Deviation map[string]float64 // (price - median) / median
+	Worst     string             // furthest from the median; needs 3 sources
+	Flagged   bool
+}
+
+// reconcilePoint is one source's price for a token, dated by the day it
+// closes
+type reconcilePoint struct {
+	Source    string
+	TokenID   string // CoinGecko id
+	Date      string
+	Timestamp int64
+	Price     float64
+}
+
+// coinGeckoCloseDay is the day a CoinGecko history point closes: the day
+// before the UTC day dailyPoints buckets it in. It is derived from the
+// timestamp because rows written before the buckets were UTC carry a local
+// date.
+func coinGeckoCloseDay(timestamp int64) string {
+	return coinGeckoDay(timestamp).AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+// reconcileSource says which source a normalized row belongs to, and the
+// day its price closes
+func reconcileSource(in UnifiedInput, r UnifiedRow) (string, string, bool) {
+	switch in.Schema {
+	case SCHEMA_COINGECKO:
+		if r.OriginalSource != "coingecko_historical" || r.Timestamp == 0 {
+			return "", "", false
+		}
+		return RECONCILE_COINGECKO, coinGeckoCloseDay(r.Timestamp), true
+	case SCHEMA_CMC:
+		return RECONCILE_CMC_API, r.Date, true
+	case SCHEMA_CMC_SCRAPER:
+		return RECONCILE_CMC_SCRAPER, r.Date, true
+	}
+	return "", "", false
+}
+
+// alignSources collects every source's prices per token and day. When a
+// source has several rows for a day the latest one is kept.
+func alignSources(reg *TokenRegistry) (map[string]*ReconcileDay, map[string]int) {
+	var points []reconcilePoint
+	if STORE_BACKEND == STORE_SQLITE {
+		points = sqliteReconcilePoints(reg)
+	} else {
+		points = csvReconcilePoints(reg)
+	}
+
+	days := make(map[string]*ReconcileDay)
+	latest := make(map[string]int64) // token|day|source -> timestamp
+	counts := make(map[string]int)   // source -> rows read
+
+	for _, pt := range points {
+		if pt.TokenID == "" || !(pt.Price > 0) {
+			continue
+		}
+		counts[pt.Source]++
+
+		key := pt.TokenID + "|" + pt.Date
+		day, ok := days[key]
+		if !ok {
+			day = &ReconcileDay{Date: pt.Date, TokenID: pt.TokenID, Prices: make(map[string]float64)}
+			if token, ok := reg.FindByCoinGeckoID(pt.TokenID); ok {
+				day.Symbol = token.Symbol
+			}
+			days[key] = day
+		}
+		if ts, seen := latest[key+"|"+pt.Source]; seen && ts > pt.Timestamp {
+			continue
+		}
+		latest[key+"|"+pt.Source] = pt.Timestamp
+		day.Prices[pt.Source] = pt.Price
+	}
+	return days, counts
+}
+
+// csvReconcilePoints reads the sources from the normalize inputs
+func csvReconcilePoints(reg *TokenRegistry) []reconcilePoint {
+	var points []reconcilePoint
+	for _, in := range UNIFIED_INPUTS {
+		rows, err := normalizeInput(in, reg)
+		if os.IsNotExist(err) {
+			fmt.Printf("   ⏭️  %s: not collected yet\n", in.DataSource)
+			continue
+		}
+		if err != nil {
+			log.Printf("Reconcile: skipping %s: %v", in.Path, err)
+			fmt.Printf("   ❌ %s: %v\n", in.Path, err)
+			continue
+		}
+
+		for _, r := range rows {
+			source, date, ok := reconcileSource(in, r)
+			if !ok {
+				continue
+			}
+			points = append(points, reconcilePoint{
+				Source: source, TokenID: r.TokenID, Date: date, Timestamp: r.Timestamp, Price: r.Price,
+			})
+		}
+	}
+	return points
+}
+
+// sqliteReconcilePoints reads the sources from the SQLite store. Rows there
+// are keyed by registry key and mapped to the CoinGecko id like the CSVs.
+func sqliteReconcilePoints(reg *TokenRegistry) []reconcilePoint {
+	if !fileExists(SQLITE_PATH) {
+		fmt.Printf("   ⏭️  %s: not collected yet\n", SQLITE_PATH)
+		return nil
+	}
+	store, err := NewSQLiteStore(SQLITE_PATH)
+	if err != nil {
+		log.Printf("Reconcile: opening %s: %v", SQLITE_PATH, err)
+		fmt.Printf("   ❌ %s: %v\n", SQLITE_PATH, err)
+		return nil
+	}
+	defer store.Close()
+
+	queries := []struct {
+		source, table, query string
+	}{
+		{RECONCILE_COINGECKO, "historical", `SELECT token, timestamp, date, price FROM historical WHERE source = ?`},
+		{RECONCILE_CMC_API, "quotes", `SELECT token, timestamp, date, price FROM quotes WHERE source = ?`},
+		{RECONCILE_CMC_SCRAPER, "ohlcv", `SELECT token, timestamp, date, close FROM ohlcv WHERE source = ?`},
+	}
+	stored := map[string]string{
+		RECONCILE_COINGECKO:   SOURCE_COINGECKO,
+		RECONCILE_CMC_API:     SOURCE_CMC,
+		RECONCILE_CMC_SCRAPER: SOURCE_CMC_SCRAPER,
+	}
+
+	var points []reconcilePoint
+	for _, q := range queries {
+		// The scraper creates its table on first write
+		if !tableExists(store.db, q.table) {
+			fmt.Printf("   ⏭️  %s: not in %s\n", q.source, SQLITE_PATH)
+			continue
+		}
+		found, err := queryReconcilePoints(store.db, q.query, stored[q.source], q.source, reg)
+		if err != nil {
+			log.Printf("Reconcile: reading %s from %s: %v", q.table, SQLITE_PATH, err)
+			fmt.Printf("   ❌ %s: %v\n", q.table, err)
+			continue
+		}
+		points = append(points, found...)
+	}
+	return points
+}
+
+// tableExists reports whether the database has the named table
+func tableExists(db *sql.DB, table string) bool {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return err == nil && n > 0
+}
+
+func queryReconcilePoints(db *sql.DB, query, stored, source string, reg *TokenRegistry) ([]reconcilePoint, error) {
+	rows, err := db.Query(query, stored)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []reconcilePoint
+	for rows.Next() {
+		var (
+			key, date string
+			timestamp int64
+			price     sql.NullFloat64
+		)
+		if err := rows.Scan(&key, &timestamp, &date, &price); err != nil {
+			return nil, err
+		}
+		token, ok := reg.Find(key)
+		if !ok || !price.Valid {
+			continue
+		}
+		if source == RECONCILE_COINGECKO {
+			date = coinGeckoCloseDay(timestamp)
+		}
+		points = append(points, reconcilePoint{
+			Source: source, TokenID: token.CoinGeckoID, Date: date, Timestamp: timestamp, Price: price.Float64,
+		})
+	}
+	return points, rows.Err()
+}
+
+// compare fills in the median, deviations and flag of a day with at least
+// two sources
+func (d *ReconcileDay) compare(threshold float64) {
+	var values []float64
+	for _, v := range d.Prices {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+
+	n := len(values)
+	if n%2 == 1 {
+		d.Median = values[n/2]
+	} else {
+		d.Median = (values[n/2-1] + values[n/2]) / 2
+	}
+	d.Spread = (values[n-1] - values[0]) / d.Median
+	d.Flagged = d.Spread > threshold
+
+	d.Deviation = make(map[string]float64)
+	worst := 0.0
+	for _, source := range RECONCILE_SOURCES {
+		v, ok := d.Prices[source]
+		if !ok {
+			continue
+		}
+		dev := (v - d.Median) / d.Median
+		d.Deviation[source] = dev
+		// With two sources both sit the same distance from the median
+		if n >= 3 && math.Abs(dev) > worst {
+			worst, d.Worst = math.Abs(dev), source
+		}
+	}
+}
+
+func (d *ReconcileDay) Record() []string {
+	record := []string{d.Date, d.TokenID, d.Symbol}
+	for _, source := range RECONCILE_SOURCES {
+		if v, ok := d.Prices[source]; ok {
+			record = append(record, formatFloat(v, 8))
+		} else {
+			record = append(record, "")
+		}
+	}
+	for _, source := range RECONCILE_SOURCES {
+		if v, ok := d.Deviation[source]; ok {
+			record = append(record, formatFloat(v, 6))
+		} else {
+			record = append(record, "")
+		}
+	}
+	return append(record,
+		strconv.Itoa(len(d.Prices)), formatFloat(d.Median, 8), formatFloat(d.Spread, 6),
+		d.Worst, strconv.FormatBool(d.Flagged))
+}
+
+func writeReconcileCSV(path string, days []*ReconcileDay) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := []string{"date", "token_id", "symbol"}
+	for _, source := range RECONCILE_SOURCES {
+		header = append(header, source)
+	}
+	for _, source := range RECONCILE_SOURCES {
+		header = append(header, source+"_deviation")
+	}
+	header = append(header, "sources", "median", "spread", "worst_source", "flagged")
+
+	writer := csv.NewWriter(file)
+	writer.Write(header)
+	for _, d := range days {
+		writer.Write(d.Record())
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// runReconcile implements the `reconcile` command
+func runReconcile(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	threshold := fs.Float64("threshold", RECONCILE_THRESHOLD, "flag days whose spread between sources is above this fraction")
+	out := fs.String("out", RECONCILE_CSV_PATH, "report CSV path")
+	all := fs.Bool("all", false, "also report days with a single source")
+	fs.Parse(args)
+
+	logFile := setup()
+	defer logFile.Close()
+
+	fmt.Println("⚖️  CROSS-SOURCE PRICE RECONCILIATION")
+	aligned, counts := alignSources(TOKEN_REGISTRY)
+	for _, source := range RECONCILE_SOURCES {
+		fmt.Printf("   %-12s %d rows\n", source, counts[source])
+	}
+
+	var days []*ReconcileDay
+	for _, d := range aligned {
+		if len(d.Prices) >= 2 {
+			d.compare(*threshold)
+		} else if !*all {
+			continue
+		}
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].TokenID != days[j].TokenID {
+			return days[i].TokenID < days[j].TokenID
+		}
+		return days[i].Date < days[j].Date
+	})
+
+	if err := writeReconcileCSV(*out, days); err != nil {
+		fmt.Printf("❌ Failed to write %s: %v\n", *out, err)
+		return 1
+	}
+
+	// Per source: how far it sits from the median and how often it is the
+	// odd one out on a flagged day
+	type sourceStats struct {
+		days     int
+		sumDev   float64
+		maxDev   float64
+		outliers int
+	}
+	stats := make(map[string]*sourceStats)
+	for _, source := range RECONCILE_SOURCES {
+		stats[source] = &sourceStats{}
+	}
+	compared, flagged := 0, 0
+	flaggedTokens := make(map[string]int)
+	for _, d := range days {
+		if d.Deviation == nil {
+			continue
+		}
+		compared++
+		for source, dev := range d.Deviation {
+			s := stats[source]
+			s.days++
+			s.sumDev += math.Abs(dev)
+			s.maxDev = math.Max(s.maxDev, math.Abs(dev))
+		}
+		if d.Flagged {
+			flagged++
+			flaggedTokens[d.TokenID]++
+			if d.Worst != "" {
+				stats[d.Worst].outliers++
+			}
+		}
+	}
+
+	fmt.Printf("\n📊 %d token-days with 2+ sources, %d flagged (spread > %.2f%%)\n", compared, flagged, *threshold*100)
+	if compared > 0 {
+		fmt.Printf("   %-12s %6s %10s %10s %9s\n", "source", "days", "mean dev", "max dev", "outlier")
+		for _, source := range RECONCILE_SOURCES {
+			s := stats[source]
+			if s.days == 0 {
+				continue
+			}
+			fmt.Printf("   %-12s %6d %9.3f%% %9.3f%% %9d\n",
+				source, s.days, s.sumDev/float64(s.days)*100, s.maxDev*100, s.outliers)
+		}
+	}
+	if len(flaggedTokens) > 0 {
+		tokens := make([]string, 0, len(flaggedTokens))
+		for id := range flaggedTokens {
+			tokens = append(tokens, id)
+		}
+		sort.Slice(tokens, func(i, j int) bool {
+			if flaggedTokens[tokens[i]] != flaggedTokens[tokens[j]] {
+				return flaggedTokens[tokens[i]] > flaggedTokens[tokens[j]]
+			}
+			return tokens[i] < tokens[j]
+		})
+		fmt.Println("\n⚠️  Flagged days per token:")
+		for _, id := range tokens {
+			fmt.Printf("   - %-20s %d\n", id, flaggedTokens[id])
+		}
+	}
+	fmt.Printf("\n📁 Report: %s\n", *out)
+	return 0
+}
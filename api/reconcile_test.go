@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoinGeckoCloseDay(t *testing.T) {
+	tests := []struct {
+		timestamp int64
+		want      string
+	}{
+		{1700006400, "2023-11-14"},           // 2023-11-15 00:00 UTC
+		{1700006400 + 23*3600, "2023-11-14"}, // still the 15th in UTC
+		{1700006400 - 1, "2023-11-13"},
+	}
+	for _, tt := range tests {
+		if got := coinGeckoCloseDay(tt.timestamp); got != tt.want {
+			t.Errorf("coinGeckoCloseDay(%d) = %s, want %s", tt.timestamp, got, tt.want)
+		}
+	}
+}
+
+func TestAlignSourcesFromSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "market_data.db")
+	oldBackend, oldPath := STORE_BACKEND, SQLITE_PATH
+	STORE_BACKEND, SQLITE_PATH = STORE_SQLITE, path
+	t.Cleanup(func() { STORE_BACKEND, SQLITE_PATH = oldBackend, oldPath })
+
+	token := TokenEntry{Key: "eth", Symbol: "ETH", CoinGeckoID: "ethereum"}
+	reg := &TokenRegistry{Tokens: []TokenEntry{token}}
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closes 2023-11-14 whatever the local date column says
+	if _, err := store.WriteHistorical(SOURCE_COINGECKO, token, []HistoricalPoint{
+		{Timestamp: 1700006400, Date: "2023-11-15", Price: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.WriteQuotes(SOURCE_CMC, []Quote{
+		{Timestamp: 1699963200, TokenKey: "eth", Price: 101}, // 2023-11-14 12:00 UTC
+		{Timestamp: 1699966800, TokenKey: "eth", Price: 102}, // later snapshot wins
+	}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	days, counts := alignSources(reg)
+	if counts[RECONCILE_COINGECKO] != 1 || counts[RECONCILE_CMC_API] != 2 {
+		t.Errorf("counts = %v", counts)
+	}
+	day, ok := days["ethereum|2023-11-14"]
+	if !ok {
+		t.Fatalf("no aligned day for ethereum 2023-11-14: %v", days)
+	}
+	if day.Prices[RECONCILE_COINGECKO] != 100 || day.Prices[RECONCILE_CMC_API] != 102 {
+		t.Errorf("prices = %v", day.Prices)
+	}
+}
+
+// Hourly points must still give one CoinGecko close per UTC day when the
+// collector runs west of UTC, where local midnight falls at 05:00 UTC
+func TestAlignSourcesHourlyPointsOutsideUTC(t *testing.T) {
+	oldLocal := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*3600)
+	t.Cleanup(func() { time.Local = oldLocal })
+
+	path := filepath.Join(t.TempDir(), "market_data.db")
+	oldBackend, oldPath := STORE_BACKEND, SQLITE_PATH
+	STORE_BACKEND, SQLITE_PATH = STORE_SQLITE, path
+	t.Cleanup(func() { STORE_BACKEND, SQLITE_PATH = oldBackend, oldPath })
+
+	token := TokenEntry{Key: "eth", Symbol: "ETH", CoinGeckoID: "ethereum"}
+	reg := &TokenRegistry{Tokens: []TokenEntry{token}}
+
+	// 2023-11-15 00:00 UTC to 2023-11-16 23:00 UTC, the price is the hour
+	start := time.Unix(1700006400, 0)
+	var prices []string
+	for h := range 48 {
+		prices = append(prices, fmt.Sprintf("[%d,%d]", start.Add(time.Duration(h)*time.Hour).UnixMilli(), 100+h))
+	}
+	body := `{"prices":[` + strings.Join(prices, ",") + `],"market_caps":[],"total_volumes":[]}`
+	srv, _ := cannedServer(t, []testResponse{{200, "", body}})
+	points, err := newTestCoinGecko(srv.URL).FetchHistorical(context.Background(), token,
+		DateRange{From: start, To: start.Add(47 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Date != "2023-11-15" || points[1].Date != "2023-11-16" {
+		t.Fatalf("points = %+v, want the first hour of 2023-11-15 and 2023-11-16", points)
+	}
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.WriteHistorical(SOURCE_COINGECKO, token, points); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	days, counts := alignSources(reg)
+	if counts[RECONCILE_COINGECKO] != 2 {
+		t.Errorf("%d CoinGecko points, want one per UTC day", counts[RECONCILE_COINGECKO])
+	}
+	for date, want := range map[string]float64{"2023-11-14": 100, "2023-11-15": 124} {
+		day, ok := days["ethereum|"+date]
+		if !ok || day.Prices[RECONCILE_COINGECKO] != want {
+			t.Errorf("close of %s = %+v, want %g", date, day, want)
+		}
+	}
+}
+
+func TestSQLiteReconcilePoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "market_data.db")
+	oldPath := SQLITE_PATH
+	SQLITE_PATH = path
+	t.Cleanup(func() { SQLITE_PATH = oldPath })
+
+	eth := TokenEntry{Key: "eth", Symbol: "ETH", CoinGeckoID: "ethereum"}
+	reg := &TokenRegistry{Tokens: []TokenEntry{eth}}
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.WriteHistorical(SOURCE_COINGECKO, eth, []HistoricalPoint{
+		{Timestamp: 1700006400, Date: "2023-11-15", Price: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Not in the registry any more
+	if _, err := store.WriteHistorical(SOURCE_COINGECKO, TokenEntry{Key: "gone"}, []HistoricalPoint{
+		{Timestamp: 1700006400, Date: "2023-11-15", Price: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.WriteQuotes(SOURCE_CMC, []Quote{
+		{Timestamp: 1699963200, TokenKey: "eth", Price: 101},
+		{Timestamp: 1699966800, TokenKey: "eth", Price: math.NaN()}, // stored as NULL
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Another source's quotes are not CMC API prices
+	if _, err := store.WriteQuotes(SOURCE_COINGECKO, []Quote{
+		{Timestamp: 1699963200, TokenKey: "eth", Price: 99},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	summarise := func(points []reconcilePoint) map[string]float64 {
+		got := make(map[string]float64)
+		for _, p := range points {
+			got[p.Source+"|"+p.TokenID+"|"+p.Date] = p.Price
+		}
+		return got
+	}
+	want := map[string]float64{
+		RECONCILE_COINGECKO + "|ethereum|2023-11-14": 100,
+		RECONCILE_CMC_API + "|ethereum|2023-11-14":   101,
+	}
+
+	// The scraper's ohlcv table does not exist yet
+	if got := summarise(sqliteReconcilePoints(reg)); !maps.Equal(got, want) {
+		t.Errorf("without ohlcv: got %v, want %v", got, want)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE ohlcv (source TEXT, token TEXT, timestamp INTEGER, date TEXT, close REAL)`,
+		`INSERT INTO ohlcv VALUES ('cmc_scraper', 'eth', 1699920000, '2023-11-14', 103)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	want[RECONCILE_CMC_SCRAPER+"|ethereum|2023-11-14"] = 103
+	if got := summarise(sqliteReconcilePoints(reg)); !maps.Equal(got, want) {
+		t.Errorf("with ohlcv: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ===== TOKEN REGISTRY =====
+// The registry (../tokens.json by default) is the single source of truth for
+// which tokens we track and how each one is identified on every data source.
+// The scraper loads the same file.
+
+// Source names used in the registry's "sources" section
+const (
+	SOURCE_COINGECKO   = "coingecko"
+	SOURCE_CMC         = "coinmarketcap"
+	SOURCE_CMC_SCRAPER = "cmc_scraper"
+	SOURCE_CG_SCRAPER  = "cg_scraper"
+)
+
+type TokenRegistry struct {
+	Sources map[string]bool `json:"sources"`
+	Tokens  []TokenEntry    `json:"tokens"`
+}
+
+type TokenEntry struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Symbol      string            `json:"symbol"`
+	CoinGeckoID string            `json:"coingecko_id"`
+	CMC         CMCMapping        `json:"cmc"`
+	ScraperSlug string            `json:"scraper_slug"`
+	Contracts   map[string]string `json:"contracts,omitempty"` // chain -> contract address
+}
+
+type CMCMapping struct {
+	ID     int    `json:"id"`
+	Slug   string `json:"slug"`
+	Symbol string `json:"symbol"`
+}
+
+func loadTokenRegistry(path string) (*TokenRegistry, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reg TokenRegistry
+	if err := json.Unmarshal(body, &reg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range reg.Tokens {
+		if t.Key == "" {
+			return nil, fmt.Errorf("token #%d in %s has no key", i+1, path)
+		}
+		if seen[t.Key] {
+			return nil, fmt.Errorf("duplicate token key %q in %s", t.Key, path)
+		}
+		seen[t.Key] = true
+	}
+
+	return &reg, nil
+}
+
+// Enabled reports whether a source is switched on in the registry
+func (r *TokenRegistry) Enabled(source string) bool {
+	return r.Sources[source]
+}
+
+// Find looks a token up by its canonical key
+func (r *TokenRegistry) Find(key string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// FindByCoinGeckoID looks a token up by its CoinGecko id
+func (r *TokenRegistry) FindByCoinGeckoID(id string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if t.CoinGeckoID == id {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// FindBySymbol looks a token up by its ticker or CMC symbol (case-insensitive)
+func (r *TokenRegistry) FindBySymbol(symbol string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if strings.EqualFold(t.Symbol, symbol) || strings.EqualFold(t.CMC.Symbol, symbol) {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// FindBySlug looks a token up by its scraper slug
+func (r *TokenRegistry) FindBySlug(slug string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if t.ScraperSlug == slug {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// Validate returns one problem per token that is missing a mapping for an
+// enabled source, plus any id that is claimed by more than one token
+func (r *TokenRegistry) Validate() []string {
+	var problems []string
+
+	if len(r.Tokens) == 0 {
+		problems = append(problems, "registry contains no tokens")
+	}
+
+	cgSeen := make(map[string]string)
+	cmcSeen := make(map[int]string)
+	slugSeen := make(map[string]string)
+
+	for _, t := range r.Tokens {
+		var missing []string
+		if t.Name == "" {
+			missing = append(missing, "name")
+		}
+		if t.Symbol == "" {
+			missing = append(missing, "symbol")
+		}
+		if (r.Enabled(SOURCE_COINGECKO) || r.Enabled(SOURCE_CG_SCRAPER)) && t.CoinGeckoID == "" {
+			missing = append(missing, "coingecko_id")
+		}
+		if r.Enabled(SOURCE_CMC) {
+			if t.CMC.ID == 0 {
+				missing = append(missing, "cmc.id")
+			}
+			if t.CMC.Slug == "" {
+				missing = append(missing, "cmc.slug")
+			}
+			if t.CMC.Symbol == "" {
+				missing = append(missing, "cmc.symbol")
+			}
+		}
+		if r.Enabled(SOURCE_CMC_SCRAPER) && t.ScraperSlug == "" {
+			missing = append(missing, "scraper_slug")
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: missing %s", t.Key, strings.Join(missing, ", ")))
+		}
+
+		if t.CoinGeckoID != "" {
+			if other, ok := cgSeen[t.CoinGeckoID]; ok {
+				problems = append(problems, fmt.Sprintf("%s: coingecko_id %q already used by %s", t.Key, t.CoinGeckoID, other))
+			}
+			cgSeen[t.CoinGeckoID] = t.Key
+		}
+		if t.CMC.ID != 0 {
+			if other, ok := cmcSeen[t.CMC.ID]; ok {
+				problems = append(problems, fmt.Sprintf("%s: cmc.id %d already used by %s", t.Key, t.CMC.ID, other))
+			}
+			cmcSeen[t.CMC.ID] = t.Key
+		}
+		if t.ScraperSlug != "" {
+			if other, ok := slugSeen[t.ScraperSlug]; ok {
+				problems = append(problems, fmt.Sprintf("%s: scraper_slug %q already used by %s", t.Key, t.ScraperSlug, other))
+			}
+			slugSeen[t.ScraperSlug] = t.Key
+		}
+	}
+
+	return problems
+}
+
+// runValidate implements the `validate` command
+func runValidate() int {
+	fmt.Printf("🔍 Validating token registry: %s\n", TOKEN_REGISTRY_PATH)
+
+	reg, err := loadTokenRegistry(TOKEN_REGISTRY_PATH)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	var enabled []string
+	for _, source := range []string{SOURCE_COINGECKO, SOURCE_CMC, SOURCE_CMC_SCRAPER, SOURCE_CG_SCRAPER} {
+		if reg.Enabled(source) {
+			enabled = append(enabled, source)
+		}
+	}
+	fmt.Printf("📊 %d tokens, enabled sources: %s\n", len(reg.Tokens), strings.Join(enabled, ", "))
+
+	problems := reg.Validate()
+	if len(problems) == 0 {
+		fmt.Println("✅ Registry is valid")
+		return 0
+	}
+
+	fmt.Printf("❌ Found %d problem(s):\n", len(problems))
+	for _, p := range problems {
+		fmt.Printf("   - %s\n", p)
+	}
+	return 1
+}
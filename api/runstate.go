@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// ===== RUN STATE =====
+// Per-token, per-source backfill checkpoints persisted between runs so a run
+// that gets throttled half way through resumes where it stopped instead of
+// treating the existing CSV as "historical done".
+
+const (
+	BACKFILL_PENDING  = "pending"
+	BACKFILL_PARTIAL  = "partial"
+	BACKFILL_COMPLETE = "complete"
+	BACKFILL_FAILED   = "failed"
+)
+
+type RunState struct {
+	UpdatedAt string                                `json:"updated_at"`
+	Backfill  map[string]map[string]*BackfillStatus `json:"backfill"` // source -> token -> status
+}
+
+type BackfillStatus struct {
+	Status    string `json:"status"`
+	From      string `json:"from,omitempty"` // first covered date (YYYY-MM-DD)
+	To        string `json:"to,omitempty"`   // last covered date (YYYY-MM-DD)
+	Records   int    `json:"records"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+
+	// Days the provider answered without a point for; the gap fill does not
+	// ask for them again
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// DateRange is an inclusive range of calendar days
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r DateRange) Days() int {
+	return int(r.To.Sub(r.From).Hours()/24) + 1
+}
+
+func (r DateRange) String() string {
+	return fmt.Sprintf("%s..%s", r.From.Format("2006-01-02"), r.To.Format("2006-01-02"))
+}
+
+func loadRunState(path string) (*RunState, error) {
+	state := &RunState{Backfill: make(map[string]map[string]*BackfillStatus)}
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if state.Backfill == nil {
+		state.Backfill = make(map[string]map[string]*BackfillStatus)
+	}
+	return state, nil
+}
+
+// Save writes the state atomically (temp file + rename) so a crash mid-write
+// never leaves a truncated checkpoint behind
+func (s *RunState) Save(path string) error {
+	s.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	body, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".run_state-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get returns the status for a token, creating a pending entry if needed
+func (s *RunState) Get(source, token string) *BackfillStatus {
+	if s.Backfill[source] == nil {
+		s.Backfill[source] = make(map[string]*BackfillStatus)
+	}
+	st, ok := s.Backfill[source][token]
+	if !ok {
+		st = &BackfillStatus{Status: BACKFILL_PENDING}
+		s.Backfill[source][token] = st
+	}
+	return st
+}
+
+// MissingRanges returns the parts of want that the token's coverage does not
+// include yet. Coverage is treated as one contiguous range.
+func (st *BackfillStatus) MissingRanges(want DateRange) []DateRange {
+	from, errFrom := time.ParseInLocation("2006-01-02", st.From, time.Local)
+	to, errTo := time.ParseInLocation("2006-01-02", st.To, time.Local)
+	if errFrom != nil || errTo != nil {
+		return []DateRange{want}
+	}
+
+	var missing []DateRange
+	if want.From.Before(from) {
+		missing = append(missing, DateRange{From: want.From, To: from.AddDate(0, 0, -1)})
+	}
+	if want.To.After(to) {
+		start := to.AddDate(0, 0, 1)
+		if start.Before(want.From) {
+			start = want.From
+		}
+		missing = append(missing, DateRange{From: start, To: want.To})
+	}
+	return missing
+}
+
+// Extend widens the coverage to include [from, to]
+func (st *BackfillStatus) Extend(from, to string) {
+	if from != "" && (st.From == "" || from < st.From) {
+		st.From = from
+	}
+	if to != "" && (st.To == "" || to > st.To) {
+		st.To = to
+	}
+}
+
+// MarkUnavailable records days the provider has no data for
+func (st *BackfillStatus) MarkUnavailable(dates ...string) {
+	for _, date := range dates {
+		if !slices.Contains(st.Unavailable, date) {
+			st.Unavailable = append(st.Unavailable, date)
+		}
+	}
+	slices.Sort(st.Unavailable)
+}
+
+func (st *BackfillStatus) touch() {
+	st.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+// historicalWindow is the range every token should eventually cover. It ends
+// yesterday: today's point is an intraday price, the current snapshot phase
+// covers it.
+func historicalWindow(now time.Time) DateRange {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return DateRange{From: today.AddDate(0, 0, -DAYS_HISTORICAL), To: today.AddDate(0, 0, -1)}
+}
+
+// pendingBackfill lists the tokens that still have missing history
+func pendingBackfill(state *RunState, source string, tokens []TokenEntry) []TokenEntry {
+	want := historicalWindow(time.Now())
+	var pending []TokenEntry
+	for _, token := range tokens {
+		if len(state.Get(source, token.Key).MissingRanges(want)) > 0 {
+			pending = append(pending, token)
+		}
+	}
+	return pending
+}
+
+// seedRunState fills in coverage for tokens that already have CoinGecko
+// history in the store, so upgrading from a state-less install does not
+// re-download what we already have
+func seedRunState(state *RunState, store Store) error {
+	present, err := store.HistoricalDates(SOURCE_COINGECKO)
+	if err != nil {
+		return err
+	}
+
+	want := historicalWindow(time.Now())
+	for key, dates := range present {
+		if len(dates) == 0 {
+			continue
+		}
+		days := slices.Sorted(maps.Keys(dates))
+		st := state.Get(SOURCE_COINGECKO, key)
+		st.Extend(days[0], days[len(days)-1])
+		st.Records = len(days)
+		st.Status = BACKFILL_PARTIAL
+		if len(st.MissingRanges(want)) == 0 {
+			st.Status = BACKFILL_COMPLETE
+		}
+		st.touch()
+	}
+	return nil
+}
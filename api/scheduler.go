@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ===== SCHEDULER =====
+// `serve` keeps the collector running and replaces the cron entry: each job
+// has its own interval, runs never overlap with themselves, and SIGTERM waits
+// for the CSV write in flight before exiting.
+
+// Missed-run policies, applied when the host slept (or the process was
+// stopped) through one or more scheduled runs
+const (
+	CATCHUP_ONCE = "once" // run once on wake-up, however many runs were missed
+	CATCHUP_SKIP = "skip" // drop the missed runs and wait for the next slot
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context)
+
+	mu      sync.Mutex // guards next, read by the job's goroutine for its log line
+	next    time.Time
+	running atomic.Bool
+}
+
+func (j *Job) nextRun() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.next
+}
+
+func (j *Job) setNext(t time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.next = t
+}
+
+type Scheduler struct {
+	Jobs    []*Job
+	Jitter  float64       // extra random delay, as a fraction of the interval
+	CatchUp string        // CATCHUP_ONCE or CATCHUP_SKIP
+	Poll    time.Duration // how often due jobs are checked
+	Grace   time.Duration // how long shutdown waits for running jobs
+
+	wg sync.WaitGroup
+}
+
+// schedule returns the job's next run time counted from now. The wall clock
+// (not the monotonic one) is used throughout so time spent suspended counts.
+func (s *Scheduler) schedule(job *Job, now time.Time) time.Time {
+	next := now.Add(job.Interval)
+	if s.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(float64(job.Interval)*s.Jitter) + 1)))
+	}
+	return next
+}
+
+// Run starts due jobs until ctx is cancelled, then waits for running ones
+func (s *Scheduler) Run(ctx context.Context) {
+	now := time.Now().Round(0)
+	for _, job := range s.Jobs {
+		// First runs start straight away, spread over a few seconds
+		job.setNext(now.Add(time.Duration(rand.Int63n(int64(5 * time.Second)))))
+	}
+
+	ticker := time.NewTicker(s.Poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().Round(0)
+		for _, job := range s.Jobs {
+			due := job.nextRun()
+			if now.Before(due) {
+				continue
+			}
+
+			if late := now.Sub(due); late > job.Interval {
+				missed := int(late / job.Interval)
+				log.Printf("Scheduler: %s missed %d run(s) (%v late)", job.Name, missed, late.Round(time.Second))
+				if s.CatchUp == CATCHUP_SKIP {
+					fmt.Printf("⏭️  [%s] Skipping %d missed run(s)\n", job.Name, missed)
+					job.setNext(s.schedule(job, now))
+					continue
+				}
+				fmt.Printf("⏰ [%s] Catching up after %d missed run(s)\n", job.Name, missed)
+			}
+
+			job.setNext(s.schedule(job, now))
+			if !job.running.CompareAndSwap(false, true) {
+				log.Printf("Scheduler: %s still running, skipping this run", job.Name)
+				fmt.Printf("⏳ [%s] Previous run still in progress, skipping\n", job.Name)
+				continue
+			}
+
+			s.wg.Add(1)
+			go s.start(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) start(ctx context.Context, job *Job) {
+	defer s.wg.Done()
+	defer job.running.Store(false)
+
+	started := time.Now()
+	log.Printf("Scheduler: %s started", job.Name)
+	fmt.Printf("\n▶️  [%s] Run started at %s\n", job.Name, started.Format("15:04:05"))
+
+	job.Run(ctx)
+
+	elapsed := time.Since(started).Round(time.Second)
+	log.Printf("Scheduler: %s finished in %v", job.Name, elapsed)
+	fmt.Printf("✅ [%s] Run finished in %v, next at %s\n", job.Name, elapsed, job.nextRun().Format("15:04:05"))
+}
+
+// shutdown waits for running jobs. Jobs stop at their next checkpoint; one
+// stuck in a request past the grace period is left behind. The caller then
+// closes the store with closeStore, which waits for the write in flight, so
+// no file is left with a partial row and a late job cannot write to a
+// closed database.
+func (s *Scheduler) shutdown() {
+	fmt.Println("\n🛑 Shutting down, waiting for running jobs...")
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("Scheduler: all jobs stopped")
+	case <-time.After(s.Grace):
+		log.Printf("Scheduler: jobs still running after %v, exiting after the write in flight", s.Grace)
+		fmt.Printf("⚠️  Jobs still running after %v, exiting\n", s.Grace)
+	}
+}
+
+// runServe implements the `serve` command
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	snapshots := fs.Duration("snapshots", 15*time.Minute, "interval between CoinGecko current snapshots")
+	cmc := fs.Duration("cmc", 15*time.Minute, "interval between CoinMarketCap quotes")
+	history := fs.Duration("history", 6*time.Hour, "interval between historical backfill/gap-fill runs")
+	jitter := fs.Float64("jitter", 0.1, "random delay added to each run, as a fraction of its interval")
+	catchUp := fs.String("catch-up", CATCHUP_ONCE, "missed-run policy: once or skip")
+	grace := fs.Duration("grace", 30*time.Second, "how long to wait for running jobs on shutdown")
+	fs.Parse(args)
+
+	if *catchUp != CATCHUP_ONCE && *catchUp != CATCHUP_SKIP {
+		fmt.Printf("❌ Unknown --catch-up policy %q (use once or skip)\n", *catchUp)
+		return 2
+	}
+
+	logFile := setup()
+	defer logFile.Close()
+
+	fmt.Println("╔════════════════════════════════════════════════════╗")
+	fmt.Println("║   CRYPTO API DATA COLLECTOR - SERVE MODE          ║")
+	fmt.Println("╚════════════════════════════════════════════════════╝")
+
+	cgExists := fileExists(CG_CSV_PATH)
+	cmcExists := fileExists(CMC_CSV_PATH)
+	STORE = openStore(cgExists, cmcExists)
+	defer closeStore()
+	state := openRunState(STORE)
+
+	// Cancelled on shutdown, which also cuts short any rate limiter backoff
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	loadMetadata(ctx, true)
+	registerProviders()
+
+	sched := &Scheduler{Jitter: *jitter, CatchUp: *catchUp, Poll: 5 * time.Second, Grace: *grace}
+
+	if p := findProvider(SOURCE_COINGECKO); p != nil {
+		sched.Jobs = append(sched.Jobs, &Job{
+			Name:     "snapshots",
+			Interval: *snapshots,
+			Run:      func(ctx context.Context) { collectQuotes(ctx, p) },
+		})
+		if !SKIP_HISTORICAL {
+			sched.Jobs = append(sched.Jobs, &Job{
+				Name:     "history",
+				Interval: *history,
+				Run: func(ctx context.Context) {
+					if pending := pendingBackfill(state, p.Name(), TOKENS); len(pending) > 0 {
+						collectHistorical(ctx, p, state, pending)
+					}
+					if ctx.Err() == nil {
+						collectCoinGeckoGaps(ctx, p, state)
+					}
+				},
+			})
+		}
+	}
+	if p := findProvider(SOURCE_CMC); p != nil {
+		sched.Jobs = append(sched.Jobs, &Job{
+			Name:     "cmc",
+			Interval: *cmc,
+			Run:      func(ctx context.Context) { collectQuotes(ctx, p) },
+		})
+	}
+
+	if len(sched.Jobs) == 0 {
+		fmt.Println("❌ No providers enabled, nothing to schedule")
+		return 1
+	}
+
+	fmt.Println("\n📅 Schedule:")
+	for _, job := range sched.Jobs {
+		fmt.Printf("   - %-10s every %v (+ up to %.0f%% jitter)\n", job.Name, job.Interval, *jitter*100)
+	}
+	fmt.Printf("⏰ Missed runs: %s\n", *catchUp)
+	fmt.Println("🛑 Stop with Ctrl+C or SIGTERM")
+
+	sched.Run(ctx)
+
+	fmt.Println("📉 API budget used since start:")
+	CG_LIMITER.Report()
+	CMC_LIMITER.Report()
+	QUARANTINE.Report()
+	return 0
+}
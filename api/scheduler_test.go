@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run with -race: the loop reschedules jobs while their goroutines log the
+// next run time
+func TestSchedulerRunsJobsWithoutRace(t *testing.T) {
+	var runs atomic.Int32
+	job := &Job{
+		Name:     "tick",
+		Interval: 20 * time.Millisecond,
+		Run: func(ctx context.Context) {
+			runs.Add(1)
+			time.Sleep(30 * time.Millisecond)
+		},
+	}
+	s := &Scheduler{Jobs: []*Job{job}, CatchUp: CATCHUP_ONCE, Poll: 5 * time.Millisecond, Grace: time.Second}
+
+	// First runs are spread over up to 5s
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+	defer cancel()
+	go func() {
+		for runs.Load() < 3 && ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	s.Run(ctx)
+
+	if n := runs.Load(); n < 3 {
+		t.Errorf("job ran %d times, want at least 3", n)
+	}
+}
+
+// countingStore counts writes and closes; the rest of Store is unused
+type countingStore struct {
+	Store
+	writes, closes atomic.Int32
+}
+
+func (s *countingStore) WriteHistorical(source string, token TokenEntry, points []HistoricalPoint) (int, error) {
+	s.writes.Add(1)
+	return len(points), nil
+}
+
+func (s *countingStore) Close() error {
+	s.closes.Add(1)
+	return nil
+}
+
+func TestCloseStoreWaitsForWriteInFlight(t *testing.T) {
+	store := &countingStore{}
+	STORE, STORE_CLOSED = store, false
+	t.Cleanup(func() { STORE_CLOSED = false })
+
+	// A write in flight holds the lock
+	STORE_MU.Lock()
+	closed := make(chan struct{})
+	go func() {
+		closeStore()
+		close(closed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if store.closes.Load() != 0 {
+		t.Fatal("store closed during a write")
+	}
+	STORE_MU.Unlock()
+	<-closed
+	if store.closes.Load() != 1 {
+		t.Fatalf("store closed %d times, want 1", store.closes.Load())
+	}
+
+	// A job that outlived the grace period writes after the close
+	point := HistoricalPoint{Timestamp: 1709251200, Date: "2024-03-01", Price: 1}
+	if n, _, _ := writeHistorical(SOURCE_COINGECKO, TokenEntry{Key: "eth"}, DateRange{}, []HistoricalPoint{point}); n != 0 {
+		t.Errorf("wrote %d rows to a closed store", n)
+	}
+	closeStore()
+	if store.writes.Load() != 0 || store.closes.Load() != 1 {
+		t.Errorf("writes=%d closes=%d after close, want 0 and 1", store.writes.Load(), store.closes.Load())
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testV1 = CSVSchema{Version: 1, Columns: []string{"timestamp", "price", "vol"}}
+
+func TestMigrateStep(t *testing.T) {
+	tests := []struct {
+		name string
+		s    CSVSchema
+		want []string
+		ok   bool
+	}{
+		{
+			name: "adds schema_version",
+			s:    CSVSchema{Version: 2, Columns: []string{"timestamp", "price", "vol", SCHEMA_VERSION_COLUMN}},
+			want: []string{"1700000000", "2.5", "10", "2"},
+			ok:   true,
+		},
+		{
+			name: "renames a column",
+			s: CSVSchema{
+				Version: 2, Columns: []string{"timestamp", "price", "volume"},
+				Renamed: map[string]string{"volume": "vol"},
+			},
+			want: []string{"1700000000", "2.5", "10"},
+			ok:   true,
+		},
+		{
+			name: "new column takes its default",
+			s: CSVSchema{
+				Version: 2, Columns: []string{"timestamp", "price", "vol", "currency"},
+				Defaults: map[string]string{"currency": "usd"},
+			},
+			want: []string{"1700000000", "2.5", "10", "usd"},
+			ok:   true,
+		},
+		{
+			name: "drops a column",
+			s:    CSVSchema{Version: 2, Columns: []string{"price", "timestamp"}, Dropped: []string{"vol"}},
+			want: []string{"2.5", "1700000000"},
+			ok:   true,
+		},
+		{
+			name: "new column without a default",
+			s:    CSVSchema{Version: 2, Columns: []string{"timestamp", "price", "vol", "currency"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.s.migrateStep(testV1, []string{"1700000000", "2.5", "10"})
+		if (err == nil) != tt.ok || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, %v; want %q, ok=%v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckMapping(t *testing.T) {
+	tests := []struct {
+		name string
+		s    CSVSchema
+		ok   bool
+	}{
+		{"keeps every column", CSVSchema{Version: 2, Columns: []string{"timestamp", "price", "vol", SCHEMA_VERSION_COLUMN}}, true},
+		{"renames a column", CSVSchema{Version: 2, Columns: []string{"timestamp", "price", "volume"}, Renamed: map[string]string{"volume": "vol"}}, true},
+		{"drops a column", CSVSchema{Version: 2, Columns: []string{"timestamp", "price"}, Dropped: []string{"vol"}}, true},
+		{"loses a column", CSVSchema{Version: 2, Columns: []string{"timestamp", "price"}}, false},
+		{"renames from a missing column", CSVSchema{Version: 2, Columns: []string{"timestamp", "price", "volume"}, Renamed: map[string]string{"volume": "volume_24h"}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.s.checkMapping(testV1); (err == nil) != tt.ok {
+			t.Errorf("%s: checkMapping = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// The shipped datasets must migrate cleanly from every version
+func TestDatasetsMap(t *testing.T) {
+	for _, d := range []*Dataset{&CG_DATASET, &CMC_DATASET, &CMC_SCRAPER_DATASET} {
+		for i := 1; i < len(d.Versions); i++ {
+			if err := d.Versions[i].checkMapping(d.Versions[i-1]); err != nil {
+				t.Errorf("%s: %v", d.Name, err)
+			}
+		}
+	}
+}
+
+func writeTestCSV(t *testing.T, path string, records [][]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	v1 := CMC_DATASET.Versions[0].Columns
+	v2 := CMC_DATASET.Current().Columns
+	tests := []struct {
+		name   string
+		header []string
+		want   string // substring of the error, "" for none
+	}{
+		{"current version", v2, ""},
+		{"older version", v1, "current version is v2"},
+		{"unknown header", []string{"timestamp", "price"}, "does not match any"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "cmc.csv")
+		writeTestCSV(t, path, [][]string{tt.header})
+		err := checkSchema(path, &CMC_DATASET)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want an error mentioning %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestMigrateCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cg.csv")
+	v1 := CG_DATASET.Versions[0].Columns
+	row := make([]string, len(v1))
+	for i, col := range v1 {
+		row[i] = col + "_value"
+	}
+	writeTestCSV(t, path, [][]string{v1, row})
+
+	// A dry run reports without touching the file
+	from, to, rows, err := migrateCSV(path, &CG_DATASET, true)
+	if err != nil || from != 1 || to != 2 || rows != 1 {
+		t.Fatalf("dry run = v%d -> v%d, %d rows, %v; want v1 -> v2, 1 row", from, to, rows, err)
+	}
+	if err := checkSchema(path, &CG_DATASET); err == nil {
+		t.Fatal("dry run rewrote the file")
+	}
+
+	if _, _, _, err := migrateCSV(path, &CG_DATASET, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSchema(path, &CG_DATASET); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(slices.Clone(row), "2"); len(records) != 2 || !slices.Equal(records[1], want) {
+		t.Errorf("migrated rows = %q, want %q", records[1:], want)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err != nil {
+		t.Errorf("no backup of the v1 file: %v", err)
+	}
+
+	// Migrating again is a no-op
+	from, to, rows, err = migrateCSV(path, &CG_DATASET, false)
+	if err != nil || from != 2 || to != 2 || rows != 0 {
+		t.Errorf("second run = v%d -> v%d, %d rows, %v; want nothing to do", from, to, rows, err)
+	}
+}
+
+func TestEnsureSchema(t *testing.T) {
+	dir := t.TempDir()
+	v1 := CMC_DATASET.Versions[0].Columns
+	row := make([]string, len(v1))
+
+	// An older file is upgraded in place, keeping a backup
+	older := filepath.Join(dir, "older.csv")
+	writeTestCSV(t, older, [][]string{v1, row})
+	if from, err := ensureSchema(older, &CMC_DATASET); err != nil || from != 1 {
+		t.Fatalf("ensureSchema = v%d, %v; want v1 upgraded", from, err)
+	}
+	if err := checkSchema(older, &CMC_DATASET); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(older + ".v1.bak"); err != nil {
+		t.Error(err)
+	}
+
+	// A missing file is left for the caller to create
+	if from, err := ensureSchema(filepath.Join(dir, "missing.csv"), &CMC_DATASET); err != nil || from != 2 {
+		t.Errorf("missing file: v%d, %v; want v2 and no error", from, err)
+	}
+
+	// An unknown header is refused and left alone
+	unknown := filepath.Join(dir, "unknown.csv")
+	writeTestCSV(t, unknown, [][]string{{"timestamp", "price"}})
+	if _, err := ensureSchema(unknown, &CMC_DATASET); err == nil {
+		t.Error("accepted a file in no known version")
+	}
+	if _, err := os.Stat(unknown + ".v1.bak"); err == nil {
+		t.Error("rewrote a file in no known version")
+	}
+}
+
+func TestUpgradeRow(t *testing.T) {
+	d := &Dataset{Name: "test", Versions: []CSVSchema{
+		testV1,
+		{Version: 2, Columns: []string{"timestamp", "price", "vol", SCHEMA_VERSION_COLUMN}},
+		{Version: 3, Columns: []string{"timestamp", "price", "volume", SCHEMA_VERSION_COLUMN}, Renamed: map[string]string{"volume": "vol"}},
+	}}
+	tests := []struct {
+		from CSVSchema
+		row  []string
+		want []string
+		ok   bool
+	}{
+		{d.Versions[0], []string{"1", "2.5", "10"}, []string{"1", "2.5", "10", "3"}, true},
+		{d.Versions[1], []string{"1", "2.5", "10", "2"}, []string{"1", "2.5", "10", "3"}, true},
+		{d.Versions[2], []string{"1", "2.5", "10", "3"}, []string{"1", "2.5", "10", "3"}, true},
+		{d.Versions[0], []string{"1", "2.5"}, nil, false}, // short row
+	}
+	for _, tt := range tests {
+		got, err := d.upgradeRow(tt.from, tt.row)
+		if (err == nil) != tt.ok || !slices.Equal(got, tt.want) {
+			t.Errorf("upgradeRow(v%d, %q) = %q, %v; want %q, ok=%v", tt.from.Version, tt.row, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// These files are kept identical in both modules
+var SHARED_FILES = []string{"schema.go", "quality.go"}
+
+func TestSharedFilesMatchScraperCopy(t *testing.T) {
+	for _, name := range SHARED_FILES {
+		ours, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		theirs, err := os.ReadFile("../scraper/" + name)
+		if err != nil {
+			t.Skipf("scraper not checked out: %v", err)
+		}
+		if string(ours) != string(theirs) {
+			t.Errorf("%s differs from ../scraper/%s; copy the change over", name, name)
+		}
+	}
+}
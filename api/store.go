@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ===== STORAGE =====
+// Collected rows go through a Store. The CSV store is the original
+// append-only layout; the SQLite store keeps one history row per (source,
+// token, date) and one quote row per (source, token, timestamp) and upserts,
+// so re-running a range replaces rows instead of duplicating them. Select with STORE_BACKEND=csv|sqlite.
+
+const (
+	STORE_CSV    = "csv"
+	STORE_SQLITE = "sqlite"
+)
+
+type Store interface {
+	WriteHistorical(source string, token TokenEntry, points []HistoricalPoint) (int, error)
+	WriteQuotes(source string, quotes []Quote) (int, error)
+	// HistoricalDates returns, per registry key, the days that already have
+	// a history row
+	HistoricalDates(source string) (map[string]map[string]bool, error)
+	Close() error
+}
+
+// openStore opens the backend chosen by STORE_BACKEND
+func openStore(cgExists, cmcExists bool) Store {
+	switch STORE_BACKEND {
+	case STORE_CSV:
+		initOutputs(cgExists, cmcExists)
+		return &CSVStore{}
+	case STORE_SQLITE:
+		store, err := NewSQLiteStore(SQLITE_PATH)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		fmt.Printf("💾 SQLite store: %s\n", SQLITE_PATH)
+		return store
+	}
+	log.Fatalf("Unknown STORE_BACKEND %q (use csv or sqlite)", STORE_BACKEND)
+	return nil
+}
+
+// ===== CSV STORE =====
+type CSVStore struct{}
+
+func (s *CSVStore) WriteHistorical(source string, token TokenEntry, points []HistoricalPoint) (int, error) {
+	switch source {
+	case SOURCE_COINGECKO:
+		return writeCoinGeckoHistoricalToCSV(token, points), nil
+	}
+	return 0, fmt.Errorf("no CSV output for %s history", source)
+}
+
+func (s *CSVStore) WriteQuotes(source string, quotes []Quote) (int, error) {
+	switch source {
+	case SOURCE_COINGECKO:
+		return writeCoinGeckoCurrentToCSV(quotes), nil
+	case SOURCE_CMC:
+		return writeCMCDataToCSV(quotes), nil
+	}
+	return 0, fmt.Errorf("no CSV output for %s quotes", source)
+}
+
+// HistoricalDates reads the CoinGecko CSV once for all tokens. Rows are in
+// write order, not date order: gap fills append older days at the end.
+func (s *CSVStore) HistoricalDates(source string) (map[string]map[string]bool, error) {
+	if source != SOURCE_COINGECKO {
+		return nil, fmt.Errorf("no CSV output for %s history", source)
+	}
+
+	file, err := os.Open(CG_CSV_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	if _, err := reader.Read(); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string) // coingecko id -> registry key
+	for _, token := range TOKENS {
+		keys[token.CoinGeckoID] = token.Key
+	}
+
+	dates := make(map[string]map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 17 || record[16] != "coingecko_historical" {
+			continue
+		}
+		key, ok := keys[record[2]]
+		if !ok {
+			continue
+		}
+		if dates[key] == nil {
+			dates[key] = make(map[string]bool)
+		}
+		dates[key][record[1]] = true
+	}
+	return dates, nil
+}
+
+func (s *CSVStore) Close() error { return nil }
+
+// ===== SQLITE STORE =====
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS historical (
+	source     TEXT    NOT NULL,
+	token      TEXT    NOT NULL,
+	timestamp  INTEGER NOT NULL,
+	date       TEXT    NOT NULL,
+	symbol     TEXT,
+	name       TEXT,
+	cmc_id     INTEGER,
+	price      REAL,
+	market_cap REAL,
+	volume     REAL,
+	PRIMARY KEY (source, token, date)
+);
+CREATE INDEX IF NOT EXISTS idx_historical_token_date ON historical (token, date);
+
+CREATE TABLE IF NOT EXISTS quotes (
+	source               TEXT    NOT NULL,
+	token                TEXT    NOT NULL,
+	timestamp            INTEGER NOT NULL,
+	date                 TEXT    NOT NULL,
+	provider_id          TEXT,
+	cmc_id               INTEGER,
+	symbol               TEXT,
+	name                 TEXT,
+	slug                 TEXT,
+	price                REAL,
+	market_cap           REAL,
+	volume_24h           REAL,
+	volume_change_24h    REAL,
+	high_24h             REAL,
+	low_24h              REAL,
+	price_change_24h     REAL,
+	percent_change_1h    REAL,
+	percent_change_24h   REAL,
+	percent_change_7d    REAL,
+	market_cap_dominance REAL,
+	circulating_supply   REAL,
+	total_supply         REAL,
+	max_supply           REAL,
+	ath                  REAL,
+	ath_date             TEXT,
+	last_updated         TEXT,
+	PRIMARY KEY (source, token, timestamp)
+);
+CREATE INDEX IF NOT EXISTS idx_quotes_token_date ON quotes (token, date);
+`
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// WAL lets readers (notebooks, exports) query while the collector writes
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) WriteHistorical(source string, token TokenEntry, points []HistoricalPoint) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO historical (source, token, timestamp, date, symbol, name, cmc_id, price, market_cap, volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, token, date) DO UPDATE SET
+			timestamp = excluded.timestamp,
+			symbol = excluded.symbol,
+			name = excluded.name,
+			cmc_id = excluded.cmc_id,
+			price = excluded.price,
+			market_cap = excluded.market_cap,
+			volume = excluded.volume`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	meta := METADATA.Resolve(token)
+	for _, pt := range points {
+		_, err := stmt.Exec(source, token.Key, pt.Timestamp, pt.Date, meta.Symbol, meta.Name, meta.CMCID,
+			pt.Price, pt.MarketCap, pt.Volume)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(points), nil
+}
+
+func (s *SQLiteStore) WriteQuotes(source string, quotes []Quote) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO quotes (
+			source, token, timestamp, date, provider_id, cmc_id, symbol, name, slug,
+			price, market_cap, volume_24h, volume_change_24h, high_24h, low_24h,
+			price_change_24h, percent_change_1h, percent_change_24h, percent_change_7d,
+			market_cap_dominance, circulating_supply, total_supply, max_supply,
+			ath, ath_date, last_updated
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, token, timestamp) DO UPDATE SET
+			date = excluded.date,
+			provider_id = excluded.provider_id,
+			cmc_id = excluded.cmc_id,
+			symbol = excluded.symbol,
+			name = excluded.name,
+			slug = excluded.slug,
+			price = excluded.price,
+			market_cap = excluded.market_cap,
+			volume_24h = excluded.volume_24h,
+			volume_change_24h = excluded.volume_change_24h,
+			high_24h = excluded.high_24h,
+			low_24h = excluded.low_24h,
+			price_change_24h = excluded.price_change_24h,
+			percent_change_1h = excluded.percent_change_1h,
+			percent_change_24h = excluded.percent_change_24h,
+			percent_change_7d = excluded.percent_change_7d,
+			market_cap_dominance = excluded.market_cap_dominance,
+			circulating_supply = excluded.circulating_supply,
+			total_supply = excluded.total_supply,
+			max_supply = excluded.max_supply,
+			ath = excluded.ath,
+			ath_date = excluded.ath_date,
+			last_updated = excluded.last_updated`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, q := range quotes {
+		token := q.TokenKey
+		if token == "" {
+			token = q.TokenID
+		}
+		_, err := stmt.Exec(
+			source, token, q.Timestamp, time.Unix(q.Timestamp, 0).Format("2006-01-02"),
+			q.TokenID, q.CMCID, q.Symbol, q.Name, q.Slug,
+			q.Price, q.MarketCap, q.Volume24h, q.VolumeChange24h, q.High24h, q.Low24h,
+			q.PriceChange24h, q.PercentChange1h, q.PercentChange24h, q.PercentChange7d,
+			q.MarketCapDominance, q.CirculatingSupply, q.TotalSupply, q.MaxSupply,
+			q.ATH, q.ATHDate, q.LastUpdated,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(quotes), nil
+}
+
+func (s *SQLiteStore) HistoricalDates(source string) (map[string]map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT token, date FROM historical WHERE source = ?`, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dates := make(map[string]map[string]bool)
+	for rows.Next() {
+		var token, date string
+		if err := rows.Scan(&token, &date); err != nil {
+			return nil, err
+		}
+		if dates[token] == nil {
+			dates[token] = make(map[string]bool)
+		}
+		dates[token][date] = true
+	}
+	return dates, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
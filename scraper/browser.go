@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== BROWSER IDENTITY =====
+// How the browser presents itself, from BROWSER_CONFIG_PATH (see
+// browser.example.json). Every context gets an identity slot, picked by the
+// rotation policy. Slot i is user agent i, viewport i and proxy i (shorter
+// lists wrap around) plus the cookies/storage the slot saved last run, so a
+// proxy always presents the same browser. Without a config file contexts
+// keep Playwright's defaults.
+
+const (
+	ROTATE_FIXED       = "fixed"       // every context uses slot 0
+	ROTATE_ROUND_ROBIN = "round_robin" // context n uses slot n
+	ROTATE_RANDOM      = "random"      // a random slot per context
+)
+
+type BrowserConfig struct {
+	Headless   *bool          `json:"headless,omitempty"` // default true
+	LaunchArgs []string       `json:"launch_args,omitempty"`
+	Locale     string         `json:"locale,omitempty"`   // e.g. en-US
+	Timezone   string         `json:"timezone,omitempty"` // IANA name, e.g. America/New_York
+	Rotation   string         `json:"rotation,omitempty"` // fixed, round_robin (default) or random
+	UserAgents []string       `json:"user_agents,omitempty"`
+	Viewports  []ViewportSize `json:"viewports,omitempty"`
+	// ${VAR} in any proxy field is taken from the environment, so
+	// credentials can stay out of the file.
+	Proxies []ProxyConfig `json:"proxies,omitempty"`
+	// Directory for per-slot cookie/storage files; empty disables them
+	StorageStateDir string `json:"storage_state_dir,omitempty"`
+
+	next atomic.Int64
+}
+
+type ViewportSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type ProxyConfig struct {
+	Server   string `json:"server"` // http://host:port or socks5://host:port
+	Bypass   string `json:"bypass,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Identity is what one browser context presents
+type Identity struct {
+	Index     int // identity slot; names the storage file
+	UserAgent string
+	Viewport  *ViewportSize
+	Proxy     *ProxyConfig
+}
+
+func loadBrowserConfig(path string) (*BrowserConfig, error) {
+	cfg := &BrowserConfig{}
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	switch cfg.Rotation {
+	case "":
+		cfg.Rotation = ROTATE_ROUND_ROBIN
+	case ROTATE_FIXED, ROTATE_ROUND_ROBIN, ROTATE_RANDOM:
+	default:
+		return nil, fmt.Errorf("%s: unknown rotation %q (use fixed, round_robin or random)", path, cfg.Rotation)
+	}
+	for i, p := range cfg.Proxies {
+		if p.Server == "" {
+			return nil, fmt.Errorf("%s: proxy %d has no server", path, i)
+		}
+		cfg.Proxies[i] = ProxyConfig{
+			Server:   os.ExpandEnv(p.Server),
+			Bypass:   os.ExpandEnv(p.Bypass),
+			Username: os.ExpandEnv(p.Username),
+			Password: os.ExpandEnv(p.Password),
+		}
+	}
+	return cfg, nil
+}
+
+// LaunchOptions applies the headless flag and extra Chromium arguments
+func (c *BrowserConfig) LaunchOptions() playwright.BrowserTypeLaunchOptions {
+	opts := playwright.BrowserTypeLaunchOptions{Headless: playwright.Bool(true)}
+	if c == nil {
+		return opts
+	}
+	if c.Headless != nil {
+		opts.Headless = c.Headless
+	}
+	opts.Args = c.LaunchArgs
+	return opts
+}
+
+// slots is the number of distinct identities, one per entry of the longest
+// list
+func (c *BrowserConfig) slots() int {
+	return max(len(c.UserAgents), len(c.Viewports), len(c.Proxies), 1)
+}
+
+// NextIdentity picks the identity for the next context
+func (c *BrowserConfig) NextIdentity() Identity {
+	n := int(c.next.Add(1) - 1)
+
+	var id Identity
+	switch c.Rotation {
+	case ROTATE_FIXED:
+		id.Index = 0
+	case ROTATE_RANDOM:
+		id.Index = rand.Intn(c.slots())
+	default:
+		id.Index = n % c.slots()
+	}
+
+	if len(c.UserAgents) > 0 {
+		id.UserAgent = c.UserAgents[id.Index%len(c.UserAgents)]
+	}
+	if len(c.Viewports) > 0 {
+		id.Viewport = &c.Viewports[id.Index%len(c.Viewports)]
+	}
+	if len(c.Proxies) > 0 {
+		id.Proxy = &c.Proxies[id.Index%len(c.Proxies)]
+	}
+	return id
+}
+
+// storagePath is the slot's saved cookies and local storage, if enabled
+func (c *BrowserConfig) storagePath(id Identity) string {
+	if c.StorageStateDir == "" {
+		return ""
+	}
+	return filepath.Join(c.StorageStateDir, fmt.Sprintf("identity-%d.json", id.Index))
+}
+
+// ContextOptions builds the options of a context with the given identity
+func (c *BrowserConfig) ContextOptions(id Identity) playwright.BrowserNewContextOptions {
+	var opts playwright.BrowserNewContextOptions
+	if c.Locale != "" {
+		opts.Locale = playwright.String(c.Locale)
+	}
+	if c.Timezone != "" {
+		opts.TimezoneId = playwright.String(c.Timezone)
+	}
+	if id.UserAgent != "" {
+		opts.UserAgent = playwright.String(id.UserAgent)
+	}
+	if id.Viewport != nil {
+		opts.Viewport = &playwright.Size{Width: id.Viewport.Width, Height: id.Viewport.Height}
+	}
+	if p := id.Proxy; p != nil {
+		opts.Proxy = &playwright.Proxy{Server: p.Server}
+		if p.Bypass != "" {
+			opts.Proxy.Bypass = playwright.String(p.Bypass)
+		}
+		if p.Username != "" {
+			opts.Proxy.Username = playwright.String(p.Username)
+			opts.Proxy.Password = playwright.String(p.Password)
+		}
+	}
+	if path := c.storagePath(id); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			opts.StorageStatePath = playwright.String(path)
+		}
+	}
+	return opts
+}
+
+// newIdentityContext opens a context with the next identity; extra adds
+// settings of the caller's own (HAR recording)
+func newIdentityContext(browser playwright.Browser, extra func(*playwright.BrowserNewContextOptions)) (playwright.BrowserContext, Identity, error) {
+	id := BROWSER_CONFIG.NextIdentity()
+	opts := BROWSER_CONFIG.ContextOptions(id)
+	if extra != nil {
+		extra(&opts)
+	}
+	ctx, err := browser.NewContext(opts)
+	return ctx, id, err
+}
+
+// saveStorageState keeps the context's cookies for the slot's next run
+func saveStorageState(ctx playwright.BrowserContext, id Identity) {
+	path := BROWSER_CONFIG.storagePath(id)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Could not create %s: %v", filepath.Dir(path), err)
+		return
+	}
+	if _, err := ctx.StorageState(path); err != nil {
+		log.Printf("Could not save storage state %s: %v", path, err)
+	}
+}
+
+// describe summarises the configuration for the run banner
+func (c *BrowserConfig) describe() string {
+	if len(c.UserAgents) == 0 && len(c.Proxies) == 0 && c.Locale == "" && c.Timezone == "" {
+		return "Playwright defaults"
+	}
+	s := fmt.Sprintf("%d user agent(s), %d viewport(s), %d proxies, rotation %s",
+		len(c.UserAgents), len(c.Viewports), len(c.Proxies), c.Rotation)
+	if c.Locale != "" || c.Timezone != "" {
+		s += fmt.Sprintf(", %s %s", c.Locale, c.Timezone)
+	}
+	return s
+}
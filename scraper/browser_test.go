@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNextIdentityBindsProxyToSlot(t *testing.T) {
+	for _, rotation := range []string{ROTATE_FIXED, ROTATE_ROUND_ROBIN, ROTATE_RANDOM} {
+		cfg := &BrowserConfig{
+			Rotation:        rotation,
+			UserAgents:      []string{"ua-0", "ua-1"},
+			Proxies:         []ProxyConfig{{Server: "p0"}, {Server: "p1"}, {Server: "p2"}},
+			StorageStateDir: "state",
+		}
+		for n := 0; n < 10; n++ {
+			id := cfg.NextIdentity()
+			if id.Index < 0 || id.Index >= 3 {
+				t.Fatalf("%s: slot %d outside the 3 configured", rotation, id.Index)
+			}
+			if rotation == ROTATE_ROUND_ROBIN && id.Index != n%3 {
+				t.Errorf("round_robin: context %d got slot %d", n, id.Index)
+			}
+			if rotation == ROTATE_FIXED && id.Index != 0 {
+				t.Errorf("fixed: context %d got slot %d", n, id.Index)
+			}
+			wantUA := fmt.Sprintf("ua-%d", id.Index%2)
+			wantProxy := fmt.Sprintf("p%d", id.Index)
+			if id.UserAgent != wantUA || id.Proxy.Server != wantProxy {
+				t.Errorf("%s: slot %d got %s via %s, want %s via %s", rotation, id.Index, id.UserAgent, id.Proxy.Server, wantUA, wantProxy)
+			}
+		}
+	}
+}
+
+func TestStoragePathPerSlot(t *testing.T) {
+	// Without user agents every context must still land in a fixed slot
+	cfg := &BrowserConfig{Rotation: ROTATE_ROUND_ROBIN, StorageStateDir: "state"}
+	paths := make(map[string]bool)
+	for n := 0; n < 5; n++ {
+		paths[cfg.storagePath(cfg.NextIdentity())] = true
+	}
+	if len(paths) != 1 {
+		t.Errorf("got %d storage files for one slot: %v", len(paths), paths)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/R-Abinav/SafeSwap.ai/scraper/numparse"
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== SELECTOR CANARY =====
+// `canary` loads one CMC history page and one CoinGecko coin page and runs
+// every selector the scrapers use against them, so markup changes show up
+// as a failing check instead of a run that quietly writes nothing. A field
+// passes when any of its selectors yields a value; the command exits 1 when
+// a required field fails.
+
+// SelectorCheck is one selector evaluated against a live page
+type SelectorCheck struct {
+	Selector string
+	Matches  int
+	Samples  []string
+	OK       bool // at least one match gave a usable value
+}
+
+// CanaryField is a value the scraper needs and the selectors that can give it
+type CanaryField struct {
+	Site     string
+	Name     string
+	Required bool
+	Checks   []SelectorCheck
+}
+
+func (f CanaryField) OK() bool {
+	for _, c := range f.Checks {
+		if c.OK {
+			return true
+		}
+	}
+	return false
+}
+
+const CANARY_SAMPLES = 3
+
+// sample trims a matched text for the report
+func sample(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 40 {
+		text = string(runes[:40]) + "…"
+	}
+	return text
+}
+
+func isNumber(text string) bool {
+	_, err := numparse.Parse(text)
+	return err == nil
+}
+
+// probeSelector counts a selector's matches and tests their text with valid
+func probeSelector(page playwright.Page, selector string, valid func(string) bool) SelectorCheck {
+	check := SelectorCheck{Selector: selector}
+
+	elems, err := page.Locator(selector).All()
+	if err != nil {
+		return check
+	}
+	check.Matches = len(elems)
+	for _, elem := range elems {
+		text, err := elem.TextContent()
+		if err != nil {
+			continue
+		}
+		if len(check.Samples) < CANARY_SAMPLES {
+			check.Samples = append(check.Samples, sample(text))
+		}
+		if valid(text) {
+			check.OK = true
+		}
+		if check.OK && len(check.Samples) >= CANARY_SAMPLES {
+			break
+		}
+	}
+	return check
+}
+
+// probeStat checks a labelled row of CoinGecko's stats tables
+func probeStat(page playwright.Page, label *regexp.Regexp) SelectorCheck {
+	check := SelectorCheck{Selector: fmt.Sprintf("tr:has(th =~ /%s/) td", label)}
+
+	text, ok := statText(page, label)
+	if !ok {
+		return check
+	}
+	check.Matches = 1
+	check.Samples = []string{sample(text)}
+	_, check.OK = statNumbers(page, label)
+	return check
+}
+
+// canaryCMC checks the history page: the data-api response, the table rows
+// and each column of the first row
+func canaryCMC(browser playwright.Browser, token TokenEntry) []CanaryField {
+	fields := []CanaryField{
+		{Site: "coinmarketcap", Name: "daily rows", Required: true},
+		{Site: "coinmarketcap", Name: "load more"},
+	}
+	columns := []string{"date", "open", "high", "low", "close", "volume", "market_cap"}
+	for _, col := range columns {
+		fields = append(fields, CanaryField{Site: "coinmarketcap", Name: "column " + col})
+	}
+
+	page, closePage, err := canaryPage(browser)
+	if err != nil {
+		log.Printf("Canary: %v", err)
+		return fields
+	}
+	defer closePage()
+
+	p := NewCMCScraperProvider(nil, nil)
+	end := time.Now()
+	url := p.historyURL(token, DateRange{From: end.AddDate(0, 0, -30), To: end})
+	fmt.Printf("🔎 %s\n", url)
+
+	capture := captureHistoryResponses(page)
+	if _, err := page.Goto(url, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(45000),
+	}); err != nil {
+		fmt.Printf("  ❌ page load failed: %v\n", err)
+		return fields
+	}
+	page.Locator(CMC_ROW_SELECTOR).First().WaitFor(playwright.LocatorWaitForOptions{
+		Timeout: playwright.Float(10000),
+	})
+
+	// The JSON the page fetched
+	responses := capture.take()
+	api := SelectorCheck{Selector: "response ~ /data-api/…/cryptocurrency/historical", Matches: len(responses)}
+	records, err := decodeHistoryResponses(responses, token, METADATA.Resolve(token))
+	if err != nil {
+		log.Printf("Canary: decoding API responses: %v", err)
+	}
+	for i := 0; i < len(records) && i < CANARY_SAMPLES; i++ {
+		api.Samples = append(api.Samples, fmt.Sprintf("%s close %g", records[i].Date, records[i].Close))
+	}
+	api.OK = len(records) > 0
+
+	// The rendered table
+	table := probeSelector(page, CMC_ROW_SELECTOR, func(string) bool { return false })
+	firstRow := page.Locator(CMC_ROW_SELECTOR).First()
+	if table.Matches > 0 {
+		_, table.OK = parseHistoryRow(firstRow, token, METADATA.Resolve(token))
+	}
+	fields[0].Checks = []SelectorCheck{api, table}
+
+	loadMore := SelectorCheck{Selector: fmt.Sprintf("%s:has-text(%q)", CMC_LOAD_MORE_SELECTOR, CMC_LOAD_MORE_TEXT)}
+	loadMore.Matches, _ = page.Locator(CMC_LOAD_MORE_SELECTOR, playwright.PageLocatorOptions{HasText: CMC_LOAD_MORE_TEXT}).Count()
+	loadMore.OK = loadMore.Matches > 0
+	fields[1].Checks = []SelectorCheck{loadMore}
+
+	// Each column of the first row, so a moved column is visible even while
+	// the JSON path keeps the scraper working
+	cells, _ := firstRow.Locator(CMC_CELL_SELECTOR).All()
+	for i := range columns {
+		check := SelectorCheck{Selector: fmt.Sprintf("%s >> %s:nth-child(%d)", CMC_ROW_SELECTOR, CMC_CELL_SELECTOR, i+1)}
+		if i < len(cells) {
+			text, _ := cells[i].TextContent()
+			check.Matches = 1
+			check.Samples = []string{sample(text)}
+			if i == 0 {
+				_, err := time.Parse("2006-01-02", parseDate(strings.TrimSpace(text)))
+				check.OK = err == nil
+			} else {
+				check.OK = isNumber(text)
+			}
+		}
+		fields[2+i].Checks = []SelectorCheck{check}
+	}
+	return fields
+}
+
+// canaryCoinGecko checks every selector and stats label of the coin page
+func canaryCoinGecko(browser playwright.Browser, token TokenEntry) []CanaryField {
+	fields := []CanaryField{
+		{Site: "coingecko", Name: "price", Required: true},
+		{Site: "coingecko", Name: "24h change"},
+	}
+	stats := []struct {
+		name     string
+		label    *regexp.Regexp
+		required bool
+	}{
+		{"market cap", cgMarketCapLabel, true},
+		{"24h volume", cgVolumeLabel, true},
+		{"circulating supply", cgCirculatingLabel, false},
+		{"total supply", cgTotalSupplyLabel, false},
+		{"max supply", cgMaxSupplyLabel, false},
+		{"24h range", cgRangeLabel, false},
+		{"all-time high", cgATHLabel, false},
+	}
+	for _, s := range stats {
+		fields = append(fields, CanaryField{Site: "coingecko", Name: s.name, Required: s.required})
+	}
+
+	page, closePage, err := canaryPage(browser)
+	if err != nil {
+		log.Printf("Canary: %v", err)
+		return fields
+	}
+	defer closePage()
+
+	url := NewCoinGeckoScraperProvider(nil, nil).pageURL(token)
+	fmt.Printf("🔎 %s\n", url)
+	if _, err := page.Goto(url, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(45000),
+	}); err != nil {
+		fmt.Printf("  ❌ page load failed: %v\n", err)
+		return fields
+	}
+
+	for _, selector := range cgPriceSelectors {
+		fields[0].Checks = append(fields[0].Checks, probeSelector(page, selector, isNumber))
+	}
+	for _, selector := range cgChangeSelectors {
+		fields[1].Checks = append(fields[1].Checks, probeSelector(page, selector, func(text string) bool {
+			return strings.Contains(text, "%") && isNumber(text)
+		}))
+	}
+	for i, s := range stats {
+		fields[2+i].Checks = []SelectorCheck{probeStat(page, s.label)}
+	}
+	return fields
+}
+
+// canaryPage opens a page in a context of its own, with the identity the
+// scrapers would use
+func canaryPage(browser playwright.Browser) (playwright.Page, func(), error) {
+	ctx, _, err := newIdentityContext(browser, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create browser context: %w", err)
+	}
+	page, err := ctx.NewPage()
+	if err != nil {
+		ctx.Close()
+		return nil, nil, fmt.Errorf("could not create page: %w", err)
+	}
+	return page, func() { ctx.Close() }, nil
+}
+
+func printCanaryField(f CanaryField) {
+	icon := "✅"
+	switch {
+	case !f.OK() && f.Required:
+		icon = "❌"
+	case !f.OK():
+		icon = "⚠️ "
+	}
+	required := ""
+	if f.Required {
+		required = " (required)"
+	}
+	fmt.Printf("  %s %s%s\n", icon, f.Name, required)
+
+	for _, c := range f.Checks {
+		mark := "·"
+		if c.OK {
+			mark = "✓"
+		}
+		fmt.Printf("      %s %-55s %3d match(es)", mark, c.Selector, c.Matches)
+		if len(c.Samples) > 0 {
+			fmt.Printf("  e.g. %q", strings.Join(c.Samples, `", "`))
+		}
+		fmt.Println()
+	}
+}
+
+// runCanary implements the `canary` command
+func runCanary(args []string) int {
+	fs := flag.NewFlagSet("canary", flag.ExitOnError)
+	key := fs.String("token", "bitcoin", "registry key of the token whose pages are checked")
+	fs.Parse(args)
+
+	logFile := setup()
+	defer logFile.Close()
+
+	token, ok := TOKEN_REGISTRY.Find(*key)
+	if !ok {
+		fmt.Printf("❌ Unknown token %q\n", *key)
+		return 2
+	}
+
+	installPlaywright()
+	pw, browser := startBrowser()
+	defer pw.Stop()
+	defer browser.Close()
+
+	var fields []CanaryField
+	if token.ScraperSlug != "" {
+		fields = append(fields, canaryCMC(browser, token)...)
+	}
+	if token.CoinGeckoID != "" {
+		fields = append(fields, canaryCoinGecko(browser, token)...)
+	}
+
+	failed := 0
+	site := ""
+	for _, f := range fields {
+		if f.Site != site {
+			site = f.Site
+			fmt.Printf("\n🐤 %s\n", site)
+		}
+		printCanaryField(f)
+		if !f.OK() {
+			log.Printf("Canary: %s %s: no selector matched (required=%v)", f.Site, f.Name, f.Required)
+			if f.Required {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n❌ %d required field(s) can no longer be extracted\n", failed)
+		return 1
+	}
+	fmt.Println("\n✅ All required fields extract")
+	return 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== COINMARKETCAP JSON CAPTURE =====
+// The history page fills its table from a background request to CMC's
+// data-api (/data-api/v3.x/cryptocurrency/historical). Reading that JSON
+// gives the exact numbers behind the rounded display strings, so the table
+// is only parsed when no such response arrived.
+
+// cmcHistoryResponse is the part of the data-api payload we use
+type cmcHistoryResponse struct {
+	Data struct {
+		ID     int    `json:"id"`
+		Symbol string `json:"symbol"`
+		Quotes []struct {
+			TimeOpen string `json:"timeOpen"`
+			Quote    struct {
+				Open      float64 `json:"open"`
+				High      float64 `json:"high"`
+				Low       float64 `json:"low"`
+				Close     float64 `json:"close"`
+				Volume    float64 `json:"volume"`
+				MarketCap float64 `json:"marketCap"`
+			} `json:"quote"`
+		} `json:"quotes"`
+	} `json:"data"`
+}
+
+func isHistoryAPI(url string) bool {
+	return strings.Contains(url, "/data-api/") && strings.Contains(url, "/cryptocurrency/historical")
+}
+
+// responseCapture keeps the page's history API responses. The handler only
+// stores them: bodies are read afterwards, outside Playwright's event loop.
+type responseCapture struct {
+	mu        sync.Mutex
+	responses []playwright.Response
+}
+
+func captureHistoryResponses(page playwright.Page) *responseCapture {
+	c := &responseCapture{}
+	page.OnResponse(func(resp playwright.Response) {
+		if resp.Status() != 200 || !isHistoryAPI(resp.URL()) {
+			return
+		}
+		c.mu.Lock()
+		c.responses = append(c.responses, resp)
+		c.mu.Unlock()
+	})
+	return c
+}
+
+// take returns the responses captured so far and starts over
+func (c *responseCapture) take() []playwright.Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	responses := c.responses
+	c.responses = nil
+	return responses
+}
+
+// decodeHistoryResponses turns captured responses into records. Payloads for
+// another coin (the page also loads sidebar widgets) are skipped.
+func decodeHistoryResponses(responses []playwright.Response, token TokenEntry, meta TokenMetadata) ([]HistoricalData, error) {
+	var records []HistoricalData
+	var errs []string
+
+	for _, resp := range responses {
+		body, err := resp.Body()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", resp.URL(), err))
+			continue
+		}
+		page, err := decodeHistoryJSON(body, token, meta)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", resp.URL(), err))
+			continue
+		}
+		records = append(records, page...)
+	}
+
+	if len(errs) > 0 {
+		return records, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return records, nil
+}
+
+func decodeHistoryJSON(body []byte, token TokenEntry, meta TokenMetadata) ([]HistoricalData, error) {
+	var payload cmcHistoryResponse
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if meta.CMCID != 0 && payload.Data.ID != 0 && payload.Data.ID != meta.CMCID {
+		return nil, nil
+	}
+
+	var records []HistoricalData
+	for _, q := range payload.Data.Quotes {
+		opened, err := time.Parse(time.RFC3339, q.TimeOpen)
+		if err != nil {
+			return records, fmt.Errorf("bad timeOpen %q", q.TimeOpen)
+		}
+		if q.Quote.Close <= 0 {
+			continue
+		}
+		records = append(records, HistoricalData{
+			TokenKey:    token.Key,
+			CMCID:       meta.CMCID,
+			Date:        opened.UTC().Format("2006-01-02"),
+			TokenSymbol: meta.Symbol,
+			TokenName:   meta.Name,
+			Open:        q.Quote.Open,
+			High:        q.Quote.High,
+			Low:         q.Quote.Low,
+			Close:       q.Quote.Close,
+			Volume:      q.Quote.Volume,
+			MarketCap:   q.Quote.MarketCap,
+			Source:      "CoinMarketCap",
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsHistoryAPI(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.coinmarketcap.com/data-api/v3.1/cryptocurrency/historical?id=1027&convertId=2781&timeStart=1704067200&timeEnd=1711929600", true},
+		{"https://api.coinmarketcap.com/data-api/v3/cryptocurrency/historical?id=1", true},
+		{"https://api.coinmarketcap.com/data-api/v3/cryptocurrency/detail/chart?id=1027", false},
+		{"https://coinmarketcap.com/currencies/ethereum/historical-data/", false},
+	}
+	for _, tt := range tests {
+		if got := isHistoryAPI(tt.url); got != tt.want {
+			t.Errorf("isHistoryAPI(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+const cmcHistoryBody = `{"data":{"id":1027,"symbol":"ETH","quotes":[
+	{"timeOpen":"2024-01-01T00:00:00.000Z","quote":{"open":2281.47,"high":2352.37,"low":2265.24,"close":2352.04,"volume":7.5e9,"marketCap":2.827e11}},
+	{"timeOpen":"2024-01-02T00:00:00.000Z","quote":{"open":2352.13,"high":2431.0,"low":2341.43,"close":2355.34,"volume":1.2e10,"marketCap":2.831e11}}
+]}}`
+
+func TestDecodeHistoryJSON(t *testing.T) {
+	token := TokenEntry{Key: "eth", ScraperSlug: "ethereum"}
+	meta := TokenMetadata{CMCID: 1027, Symbol: "ETH", Name: "Ethereum"}
+
+	tests := []struct {
+		name    string
+		body    string
+		meta    TokenMetadata
+		dates   []string
+		wantErr bool
+	}{
+		{"two days", cmcHistoryBody, meta, []string{"2024-01-01", "2024-01-02"}, false},
+		{"id not resolved yet", cmcHistoryBody, TokenMetadata{}, []string{"2024-01-01", "2024-01-02"}, false},
+		{"another coin's widget", `{"data":{"id":1,"symbol":"BTC","quotes":[{"timeOpen":"2024-01-01T00:00:00.000Z","quote":{"close":42000}}]}}`, meta, nil, false},
+		{"day opening at a UTC offset", `{"data":{"id":1027,"quotes":[{"timeOpen":"2024-01-01T23:00:00-02:00","quote":{"close":2352.04}}]}}`, meta, []string{"2024-01-02"}, false},
+		{"zero close is skipped", `{"data":{"id":1027,"quotes":[{"timeOpen":"2024-01-01T00:00:00.000Z","quote":{"close":0}}]}}`, meta, nil, false},
+		{"bad timeOpen", `{"data":{"id":1027,"quotes":[{"timeOpen":"yesterday","quote":{"close":1}}]}}`, meta, nil, true},
+		{"malformed body", `{"data":{"id":1027,"quotes":[`, meta, nil, true},
+	}
+	for _, tt := range tests {
+		records, err := decodeHistoryJSON([]byte(tt.body), token, tt.meta)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		var dates []string
+		for _, d := range records {
+			dates = append(dates, d.Date)
+		}
+		if !slices.Equal(dates, tt.dates) {
+			t.Errorf("%s: dates %v, want %v", tt.name, dates, tt.dates)
+		}
+	}
+}
+
+func TestDecodeHistoryJSONFields(t *testing.T) {
+	meta := TokenMetadata{CMCID: 1027, Symbol: "ETH", Name: "Ethereum"}
+	records, err := decodeHistoryJSON([]byte(cmcHistoryBody), TokenEntry{Key: "eth"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HistoricalData{
+		TokenKey: "eth", CMCID: 1027, Date: "2024-01-01", TokenSymbol: "ETH", TokenName: "Ethereum",
+		Open: 2281.47, High: 2352.37, Low: 2265.24, Close: 2352.04, Volume: 7.5e9, MarketCap: 2.827e11,
+		Source: "CoinMarketCap",
+	}
+	if len(records) == 0 || records[0] != want {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+}
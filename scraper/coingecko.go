@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/R-Abinav/SafeSwap.ai/scraper/numparse"
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== COINGECKO SCRAPER PROVIDER =====
+// Keyless fallback for the API collector's CoinGecko snapshots. It loads each
+// coin page and reads the price plus the stats and price-history tables into
+// the API's current-data columns. Every field has a list of selectors, newest
+// page layout first, grown from the probes in test/test.go.
+
+var (
+	cgPriceSelectors = []string{
+		"span[data-converter-target='price'][data-coin-id]",
+		"[data-target='price.price']",
+		"span[data-converter-target='price']",
+		"span[class*='price']",
+		"span.no-wrap",
+	}
+	cgChangeSelectors = []string{
+		"[data-coin-id] ~ span [data-price-change-target]",
+		"span[data-price-change-target='percent']",
+		"[data-target='percent-change.percentChange']",
+		"span[class*='gecko-up'], span[class*='gecko-down']",
+	}
+
+	// Row labels of the stats and price-history tables
+	cgMarketCapLabel   = regexp.MustCompile(`^\s*Market Cap\s*$`)
+	cgVolumeLabel      = regexp.MustCompile(`^\s*(24 Hour Trading Vol|Trading Volume \(24h\)|24h Trading Volume)\s*$`)
+	cgCirculatingLabel = regexp.MustCompile(`^\s*Circulating Supply\s*$`)
+	cgTotalSupplyLabel = regexp.MustCompile(`^\s*Total Supply\s*$`)
+	cgMaxSupplyLabel   = regexp.MustCompile(`^\s*Max Supply\s*$`)
+	cgRangeLabel       = regexp.MustCompile(`^\s*24h Range\s*$`)
+	cgATHLabel         = regexp.MustCompile(`^\s*All-[Tt]ime [Hh]igh\s*$`)
+
+	// Numbers inside cells that carry more text ("19,784,215 BTC")
+	cgNumber = regexp.MustCompile(`[$€£]?\d[\d,]*(?:\.\d+)?(?:[₀-₉]\d+)?`)
+	cgDate   = regexp.MustCompile(`[A-Z][a-z]{2} \d{1,2}, \d{4}`)
+)
+
+type CoinGeckoScraperProvider struct {
+	BaseURL    string
+	pool       *ContextPool
+	politeness *Politeness
+}
+
+func NewCoinGeckoScraperProvider(pool *ContextPool, politeness *Politeness) *CoinGeckoScraperProvider {
+	return &CoinGeckoScraperProvider{
+		BaseURL:    envOr("CG_SITE_URL", "https://www.coingecko.com"),
+		pool:       pool,
+		politeness: politeness,
+	}
+}
+
+func (p *CoinGeckoScraperProvider) Name() string { return SOURCE_CG_SCRAPER }
+
+// A batch is written as soon as it is scraped, so an interrupted run keeps
+// what it has
+func (p *CoinGeckoScraperProvider) Capabilities() Capabilities {
+	return Capabilities{Historical: false, Quotes: true, MaxBatch: 10}
+}
+
+func (p *CoinGeckoScraperProvider) FetchHistorical(token TokenEntry, r DateRange) ([]HistoricalData, error) {
+	return nil, ErrNotSupported
+}
+
+// FetchQuotes scrapes the tokens' pages on up to SCRAPE_WORKERS contexts.
+// Quotes come back in token order; tokens that failed are left out and
+// named in the error.
+func (p *CoinGeckoScraperProvider) FetchQuotes(tokens []TokenEntry) ([]Quote, error) {
+	quotes := make([]*Quote, len(tokens))
+	errs := make([]error, len(tokens))
+
+	sem := make(chan struct{}, SCRAPE_WORKERS)
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, token TokenEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			q, err := p.fetchQuote(token)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", token.CoinGeckoID, err)
+				return
+			}
+			quotes[i] = &q
+		}(i, token)
+	}
+	wg.Wait()
+
+	var out []Quote
+	for _, q := range quotes {
+		if q != nil {
+			out = append(out, *q)
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+func (p *CoinGeckoScraperProvider) pageURL(token TokenEntry) string {
+	return fmt.Sprintf("%s/en/coins/%s", p.BaseURL, token.CoinGeckoID)
+}
+
+func (p *CoinGeckoScraperProvider) fetchQuote(token TokenEntry) (Quote, error) {
+	browserCtx, err := p.pool.Acquire("coingecko-" + token.CoinGeckoID)
+	if err != nil {
+		return Quote{}, fmt.Errorf("could not create browser context: %w", err)
+	}
+	defer p.pool.Release(browserCtx)
+
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return Quote{}, fmt.Errorf("could not create page: %w", err)
+	}
+	defer page.Close()
+
+	url := p.pageURL(token)
+	name := "coingecko-" + token.CoinGeckoID
+	forensics := attachForensics(browserCtx, page)
+	forensics.Begin()
+	fail := func(err error) error {
+		RUN.Fail(Failure{
+			Token:     token.Key,
+			Page:      name,
+			URL:       url,
+			Error:     err.Error(),
+			Forensics: forensics.Save(RUN, name, err),
+		})
+		return err
+	}
+
+	if HAR_MODE != HAR_REPLAY {
+		p.politeness.Wait()
+	}
+	_, err = page.Goto(url, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(45000),
+	})
+	if err != nil {
+		fmt.Printf("  ⚠️  %s: page load timeout\n", token.CoinGeckoID)
+		return Quote{}, fail(fmt.Errorf("could not goto page: %w", err))
+	}
+
+	meta := METADATA.Resolve(token)
+	nan := math.NaN()
+	q := Quote{
+		Timestamp: time.Now().Unix(),
+		TokenKey:  token.Key,
+		TokenID:   token.CoinGeckoID,
+		CMCID:     meta.CMCID,
+		Symbol:    meta.Symbol,
+		Name:      meta.Name,
+		MarketCap: nan, Volume24h: nan, VolumeChange24h: nan, High24h: nan, Low24h: nan,
+		PriceChange24h: nan, PercentChange1h: nan, PercentChange24h: nan, PercentChange7d: nan,
+		MarketCapDominance: nan, CirculatingSupply: nan, TotalSupply: nan, MaxSupply: nan, ATH: nan,
+	}
+
+	price, ok := firstNumber(page, cgPriceSelectors)
+	if !ok {
+		return q, fail(fmt.Errorf("no price found"))
+	}
+	q.Price = price
+	forensics.Discard()
+
+	if pct, ok := priceChangePercent(page); ok {
+		q.PercentChange24h = pct
+		// The page shows only the percentage; work back to the absolute change
+		q.PriceChange24h = price - price/(1+pct/100)
+	}
+
+	if v, ok := statNumbers(page, cgMarketCapLabel); ok {
+		q.MarketCap = v[0]
+	}
+	if v, ok := statNumbers(page, cgVolumeLabel); ok {
+		q.Volume24h = v[0]
+	}
+	if v, ok := statNumbers(page, cgCirculatingLabel); ok {
+		q.CirculatingSupply = v[0]
+	}
+	if v, ok := statNumbers(page, cgTotalSupplyLabel); ok {
+		q.TotalSupply = v[0]
+	}
+	if v, ok := statNumbers(page, cgMaxSupplyLabel); ok {
+		q.MaxSupply = v[0]
+	}
+	if v, ok := statNumbers(page, cgRangeLabel); ok && len(v) >= 2 {
+		q.Low24h, q.High24h = v[0], v[1]
+	}
+	if v, ok := statNumbers(page, cgATHLabel); ok {
+		q.ATH = v[0]
+		if text, ok := statText(page, cgATHLabel); ok {
+			if d := cgDate.FindString(text); d != "" {
+				q.ATHDate = parseDate(d)
+			}
+		}
+	}
+
+	fmt.Printf("  ✅ %s: $%s\n", strings.ToUpper(token.CoinGeckoID), formatPrice(q.Price))
+	return q, nil
+}
+
+// firstNumber returns the first selector match that parses as a number
+func firstNumber(page playwright.Page, selectors []string) (float64, bool) {
+	for _, selector := range selectors {
+		elems, err := page.Locator(selector).All()
+		if err != nil {
+			continue
+		}
+		for _, elem := range elems {
+			text, err := elem.TextContent()
+			if err != nil || !strings.ContainsAny(text, "0123456789") {
+				continue
+			}
+			if v, err := numparse.Parse(text); err == nil {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// priceChangePercent reads the 24h change next to the price. The page
+// shows the direction with an up/down class rather than a minus sign.
+func priceChangePercent(page playwright.Page) (float64, bool) {
+	for _, selector := range cgChangeSelectors {
+		elem := page.Locator(selector).First()
+		if n, _ := elem.Count(); n == 0 {
+			continue
+		}
+		text, err := elem.TextContent()
+		if err != nil || !strings.Contains(text, "%") {
+			continue
+		}
+		v, err := numparse.Parse(text)
+		if err != nil {
+			continue
+		}
+		class, _ := elem.GetAttribute("class")
+		if strings.Contains(class, "down") && v > 0 {
+			v = -v
+		}
+		return v, true
+	}
+	return 0, false
+}
+
+// statText returns the value cell of the table row whose header matches
+// label
+func statText(page playwright.Page, label *regexp.Regexp) (string, bool) {
+	row := page.Locator("tr", playwright.PageLocatorOptions{
+		Has: page.Locator("th", playwright.PageLocatorOptions{HasText: label}),
+	})
+	cell := row.Locator("td").First()
+	if n, _ := cell.Count(); n == 0 {
+		return "", false
+	}
+	text, err := cell.TextContent()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(text), true
+}
+
+// statNumbers parses every number in a stats row's value cell, in order
+func statNumbers(page playwright.Page, label *regexp.Regexp) ([]float64, bool) {
+	text, ok := statText(page, label)
+	if !ok {
+		return nil, false
+	}
+
+	var values []float64
+	for _, match := range cgNumber.FindAllString(text, -1) {
+		if v, err := numparse.Parse(match); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values, len(values) > 0
+}
+
+// formatPrice prints small prices with enough digits to be readable
+func formatPrice(v float64) string {
+	if v < 1 {
+		return fmt.Sprintf("%.8f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// ===== COINGECKO RUN =====
+
+// runCoinGecko implements the `coingecko` command: one snapshot of every
+// token with a CoinGecko id, written in the API's current-data columns
+func runCoinGecko() int {
+	logFile := setup()
+	defer logFile.Close()
+
+	if !TOKEN_REGISTRY.Enabled(SOURCE_CG_SCRAPER) {
+		fmt.Println("⏭️  CoinGecko scraper disabled in token registry")
+		return 0
+	}
+
+	var tokens []TokenEntry
+	for _, t := range TOKEN_REGISTRY.Tokens {
+		if t.CoinGeckoID == "" {
+			fmt.Printf("⚠️  %s has no coingecko_id, skipping\n", t.Key)
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+
+	fmt.Printf("🦎 Scraping CoinGecko pages for %d tokens\n", len(tokens))
+	fmt.Printf("📁 Output: %s\n", quotesOutputPath())
+	printHARMode()
+
+	STORE = openStore()
+	defer STORE.Close()
+	RUN = startRun("coingecko")
+
+	installPlaywright()
+
+	pw, browser := startBrowser()
+	defer pw.Stop()
+	defer browser.Close()
+
+	pool, err := NewContextPool(browser, SCRAPE_WORKERS)
+	if err != nil {
+		log.Fatalf("Could not create browser contexts: %v", err)
+	}
+	defer pool.Close()
+
+	p := NewCoinGeckoScraperProvider(pool, NewPoliteness(SCRAPE_DELAY))
+	batchSize := p.Capabilities().MaxBatch
+	total, failed := 0, 0
+	for start := 0; start < len(tokens); start += batchSize {
+		end := start + batchSize
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		quotes, err := p.FetchQuotes(tokens[start:end])
+		if err != nil {
+			log.Printf("CoinGecko scrape errors: %v", err)
+			failed += end - start - len(quotes)
+		}
+		quotes = QUARANTINE.validQuotes(p.Name(), quotes)
+		if len(quotes) == 0 {
+			continue
+		}
+		if err := STORE.WriteQuotes(p.Name(), quotes); err != nil {
+			log.Printf("Error writing CoinGecko quotes: %v", err)
+			fmt.Printf("  ❌ error writing %d quotes\n", len(quotes))
+			continue
+		}
+		total += len(quotes)
+	}
+
+	fmt.Printf("\n✅ Saved %d quotes", total)
+	if failed > 0 {
+		fmt.Printf(", %d tokens failed (see %s)", failed, LOG_PATH)
+	}
+	fmt.Println()
+	RUN.Quarantined = QUARANTINE.Rules()
+	QUARANTINE.Report()
+
+	RUN.Tokens, RUN.Records = len(tokens), total
+	RUN.Finish()
+	if total == 0 {
+		return 1
+	}
+	return 0
+}
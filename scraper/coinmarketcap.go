@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/R-Abinav/SafeSwap.ai/scraper/numparse"
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== COINMARKETCAP SCRAPER PROVIDER =====
+
+// Selectors of the history page; `go run . canary` checks they still match
+var (
+	CMC_ROW_SELECTOR       = "table tbody tr"
+	CMC_CELL_SELECTOR      = "td"
+	CMC_LOAD_MORE_SELECTOR = "button"
+	CMC_LOAD_MORE_TEXT     = "Load More"
+)
+
+// CMCScraperProvider is safe for concurrent use: every call borrows a
+// browser context from the pool and waits its turn on the politeness limiter
+type CMCScraperProvider struct {
+	BaseURL    string
+	pool       *ContextPool
+	politeness *Politeness
+}
+
+func NewCMCScraperProvider(pool *ContextPool, politeness *Politeness) *CMCScraperProvider {
+	return &CMCScraperProvider{
+		BaseURL:    envOr("CMC_SITE_URL", "https://coinmarketcap.com"),
+		pool:       pool,
+		politeness: politeness,
+	}
+}
+
+func (p *CMCScraperProvider) Name() string { return SOURCE_CMC_SCRAPER }
+
+func (p *CMCScraperProvider) Capabilities() Capabilities {
+	return Capabilities{Historical: true, Quotes: false}
+}
+
+func (p *CMCScraperProvider) FetchQuotes(tokens []TokenEntry) ([]Quote, error) {
+	return nil, ErrNotSupported
+}
+
+// FetchHistorical loads the token's historical-data page once per window of
+// the range, pages each table to the end and stitches the rows together.
+// Rows come from the page's JSON responses when it made any (cmcjson.go),
+// otherwise from the rendered table.
+func (p *CMCScraperProvider) FetchHistorical(token TokenEntry, r DateRange) ([]HistoricalData, error) {
+	var records []HistoricalData
+
+	// Create a new page in a context of our own
+	browserCtx, err := p.pool.Acquire(token.ScraperSlug)
+	if err != nil {
+		return records, fmt.Errorf("could not create browser context: %w", err)
+	}
+	defer p.pool.Release(browserCtx)
+
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return records, fmt.Errorf("could not create page: %w", err)
+	}
+	defer page.Close()
+
+	// One URL per window; a replay asks for the pages exactly as recorded
+	var urls []string
+	if HAR_MODE == HAR_REPLAY {
+		if urls, err = recordedPageURLs(token.ScraperSlug); err != nil {
+			return records, err
+		}
+	} else {
+		for _, w := range splitRange(r, SCRAPE_WINDOW_DAYS) {
+			urls = append(urls, p.historyURL(token, w))
+		}
+	}
+
+	meta := METADATA.Resolve(token)
+	seen := make(map[string]bool)
+	var lastErr error
+
+	capture := captureHistoryResponses(page)
+	forensics := attachForensics(browserCtx, page)
+	for i, url := range urls {
+		forensics.Begin()
+		rows, err := p.loadWindow(page, url)
+
+		// Prefer the page's own API responses; the table is the fallback
+		window, jsonErr := decodeHistoryResponses(capture.take(), token, meta)
+		if jsonErr != nil {
+			log.Printf("%s window %d/%d: decoding API responses: %v", token.ScraperSlug, i+1, len(urls), jsonErr)
+		}
+		via := "api"
+		if len(window) == 0 && err == nil {
+			via = "table"
+			for _, row := range rows {
+				if data, ok := parseHistoryRow(row, token, meta); ok {
+					window = append(window, data)
+				}
+			}
+			if len(window) == 0 {
+				err = fmt.Errorf("%d table rows but none parsed", len(rows))
+			}
+		}
+		if len(window) == 0 {
+			name := token.ScraperSlug
+			if len(urls) > 1 {
+				name = fmt.Sprintf("%s-window%d", token.ScraperSlug, i+1)
+			}
+			log.Printf("%s window %d/%d (%s): %v", token.ScraperSlug, i+1, len(urls), url, err)
+			fmt.Printf("  ⚠️  %s: window %d/%d failed: %v\n", token.ScraperSlug, i+1, len(urls), err)
+			RUN.Fail(Failure{
+				Token:     token.Key,
+				Page:      name,
+				URL:       url,
+				Error:     err.Error(),
+				Forensics: forensics.Save(RUN, name, err),
+			})
+			lastErr = err
+			continue
+		}
+		forensics.Discard()
+
+		var added int
+		records, added = stitchWindow(records, window, seen)
+		fmt.Printf("  📊 %s: window %d/%d: %d rows from %s, %d new days\n",
+			token.ScraperSlug, i+1, len(urls), len(window), via, added)
+	}
+
+	// Failed windows surface as gaps in the coverage report; only a token
+	// with nothing at all is an error
+	if len(records) == 0 && lastErr != nil {
+		return records, lastErr
+	}
+	return records, nil
+}
+
+// stitchWindow appends a window's records for days not seen yet. Windows may
+// overlap on their edges; the first row for a day wins.
+func stitchWindow(records, window []HistoricalData, seen map[string]bool) ([]HistoricalData, int) {
+	added := 0
+	for _, data := range window {
+		if seen[data.Date] {
+			continue
+		}
+		seen[data.Date] = true
+		records = append(records, data)
+		added++
+	}
+	return records, added
+}
+
+func (p *CMCScraperProvider) historyURL(token TokenEntry, r DateRange) string {
+	return fmt.Sprintf("%s/currencies/%s/historical-data/?start=%s&end=%s",
+		p.BaseURL, token.ScraperSlug, r.From.Format("20060102"), r.To.Format("20060102"))
+}
+
+// loadWindow opens one history page and returns its table rows once the
+// table has stopped growing
+func (p *CMCScraperProvider) loadWindow(page playwright.Page, url string) ([]playwright.Locator, error) {
+	// Replays are offline, so need no spacing
+	if HAR_MODE != HAR_REPLAY {
+		p.politeness.Wait()
+	}
+	_, err := page.Goto(url, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(45000), // 45 second timeout
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not goto page: %w", err)
+	}
+
+	// Wait for the table to render instead of sleeping a fixed time
+	rowLocator := page.Locator(CMC_ROW_SELECTOR)
+	rowLocator.First().WaitFor(playwright.LocatorWaitForOptions{
+		Timeout: playwright.Float(10000),
+	})
+
+	if err := loadAllRows(page, rowLocator); err != nil {
+		return nil, err
+	}
+
+	rows, err := rowLocator.All()
+	if err != nil || len(rows) == 0 {
+		return nil, fmt.Errorf("no table rows found")
+	}
+	return rows, nil
+}
+
+// loadAllRows presses "Load More", or scrolls for lazily loaded tables,
+// until a round adds no rows
+func loadAllRows(page playwright.Page, rows playwright.Locator) error {
+	count, err := rows.Count()
+	if err != nil {
+		return err
+	}
+
+	loadMore := page.Locator(CMC_LOAD_MORE_SELECTOR, playwright.PageLocatorOptions{HasText: CMC_LOAD_MORE_TEXT}).First()
+	for round := 0; round < MAX_LOAD_ROUNDS; round++ {
+		if visible, _ := loadMore.IsVisible(); visible {
+			err := loadMore.Click(playwright.LocatorClickOptions{Timeout: playwright.Float(5000)})
+			if err != nil {
+				log.Printf("Clicking Load More failed: %v", err)
+			}
+		} else {
+			page.Mouse().Wheel(0, 10000)
+		}
+
+		grown := waitForRows(rows, count, 3*time.Second)
+		if grown <= count {
+			return nil
+		}
+		count = grown
+	}
+	log.Printf("Table still growing after %d load rounds at %d rows", MAX_LOAD_ROUNDS, count)
+	return nil
+}
+
+// waitForRows polls the row count until it exceeds `than` or timeout passes
+func waitForRows(rows playwright.Locator, than int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		count, err := rows.Count()
+		if err == nil && count > than {
+			return count
+		}
+		if time.Now().After(deadline) {
+			return than
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// parseHistoryRow reads one table row. A cell that does not parse is
+// logged; without a date or close price the row is dropped.
+func parseHistoryRow(row playwright.Locator, token TokenEntry, meta TokenMetadata) (HistoricalData, bool) {
+	data := HistoricalData{
+		TokenKey:    token.Key,
+		CMCID:       meta.CMCID,
+		TokenSymbol: meta.Symbol,
+		TokenName:   meta.Name,
+		Source:      "CoinMarketCap",
+	}
+
+	cells, err := row.Locator(CMC_CELL_SELECTOR).All()
+	if err != nil || len(cells) < 7 {
+		return data, false
+	}
+
+	// Extract date (column 0)
+	dateText, _ := cells[0].TextContent()
+	data.Date = parseDate(strings.TrimSpace(dateText))
+
+	// Extract OHLC, volume and market cap
+	fields := []struct {
+		name string
+		dst  *float64
+	}{
+		{"open", &data.Open}, {"high", &data.High}, {"low", &data.Low}, {"close", &data.Close},
+		{"volume", &data.Volume}, {"market_cap", &data.MarketCap},
+	}
+	closeOK := true
+	for i, f := range fields {
+		v, err := parseCell(cells, i+1)
+		if err != nil {
+			if !errors.Is(err, numparse.ErrEmpty) {
+				log.Printf("%s %s: %s: %v", token.ScraperSlug, data.Date, f.name, err)
+			}
+			if f.name == "close" {
+				closeOK = false
+			}
+			continue
+		}
+		*f.dst = v
+	}
+
+	return data, data.Date != "" && closeOK && data.Close > 0
+}
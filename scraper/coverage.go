@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ===== DATE WINDOWS & COVERAGE =====
+// The history page only renders so many rows per request, so a long range is
+// scraped as SCRAPE_WINDOW_DAYS windows and stitched back together. Every
+// token is then checked day by day: a truncated table shows up as a gap in
+// the coverage report instead of silently shrinking the dataset.
+
+// splitRange cuts r into consecutive windows of at most `days` days, oldest
+// first
+func splitRange(r DateRange, days int) []DateRange {
+	from, to := dayOf(r.From), dayOf(r.To)
+	var windows []DateRange
+	for start := from; !start.After(to); start = start.AddDate(0, 0, days) {
+		end := start.AddDate(0, 0, days-1)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, DateRange{From: start, To: end})
+	}
+	return windows
+}
+
+// recordSpan is the range from the first to the last record's date
+func recordSpan(records []HistoricalData) DateRange {
+	var span DateRange
+	for _, d := range records {
+		t, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			continue
+		}
+		if span.From.IsZero() || t.Before(span.From) {
+			span.From = t
+		}
+		if t.After(span.To) {
+			span.To = t
+		}
+	}
+	return span
+}
+
+// Coverage is how many of a range's days a token has rows for
+type Coverage struct {
+	Token    string   `json:"token"`
+	Expected int      `json:"expected"`
+	Found    int      `json:"found"`
+	Missing  []string `json:"missing"` // oldest first
+}
+
+func (c Coverage) Percent() float64 {
+	if c.Expected == 0 {
+		return 0
+	}
+	return 100 * float64(c.Found) / float64(c.Expected)
+}
+
+// Gaps folds the missing days into "from..to" runs
+func (c Coverage) Gaps() []string {
+	var gaps []string
+	for i := 0; i < len(c.Missing); {
+		j := i
+		for j+1 < len(c.Missing) && nextDay(c.Missing[j]) == c.Missing[j+1] {
+			j++
+		}
+		if i == j {
+			gaps = append(gaps, c.Missing[i])
+		} else {
+			gaps = append(gaps, c.Missing[i]+".."+c.Missing[j])
+		}
+		i = j + 1
+	}
+	return gaps
+}
+
+func nextDay(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, 1).Format("2006-01-02")
+}
+
+// checkCoverage compares the records against every completed day of r.
+// Today is left out: its candle only appears once the day closes.
+func checkCoverage(token string, records []HistoricalData, r DateRange) Coverage {
+	c := Coverage{Token: token}
+	if r.From.IsZero() {
+		return c
+	}
+
+	have := make(map[string]bool, len(records))
+	for _, d := range records {
+		have[d.Date] = true
+	}
+
+	last := dayOf(r.To)
+	if today := dayOf(time.Now()); !last.Before(today) {
+		last = today.AddDate(0, 0, -1)
+	}
+	for t := dayOf(r.From); !t.After(last); t = t.AddDate(0, 0, 1) {
+		date := t.Format("2006-01-02")
+		c.Expected++
+		if have[date] {
+			c.Found++
+		} else {
+			c.Missing = append(c.Missing, date)
+		}
+	}
+	return c
+}
+
+// printCoverage reports one token's coverage and logs every gap
+func printCoverage(c Coverage) {
+	slug := strings.ToUpper(c.Token)
+	if len(c.Missing) == 0 {
+		fmt.Printf("  📅 %s: %d/%d days, contiguous\n", slug, c.Found, c.Expected)
+		return
+	}
+
+	gaps := c.Gaps()
+	log.Printf("Coverage %s: %d/%d days, gaps: %s", c.Token, c.Found, c.Expected, strings.Join(gaps, ", "))
+
+	shown := gaps
+	if len(shown) > 3 {
+		shown = append(shown[:3:3], fmt.Sprintf("+%d more", len(gaps)-3))
+	}
+	fmt.Printf("  ⚠️  %s: %d/%d days (%.1f%%), %d gaps: %s\n",
+		slug, c.Found, c.Expected, c.Percent(), len(gaps), strings.Join(shown, ", "))
+}
+
+// printCoverageReport prints the end-of-run table, worst coverage first
+func printCoverageReport(report []Coverage) {
+	if len(report) == 0 {
+		return
+	}
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Percent() < report[j].Percent() })
+
+	fmt.Println("\n📅 Coverage by token:")
+	fmt.Printf("   %-20s %9s %8s %5s\n", "TOKEN", "DAYS", "COVERAGE", "GAPS")
+	for _, c := range report {
+		fmt.Printf("   %-20s %4d/%-4d %7.1f%% %5d\n", c.Token, c.Found, c.Expected, c.Percent(), len(c.Gaps()))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitRange(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		from, to string
+		days     int
+		want     []string
+	}{
+		{"2024-01-01", "2024-01-01", 90, []string{"20240101-20240101"}},
+		{"2024-01-01", "2024-01-10", 10, []string{"20240101-20240110"}},
+		{"2024-01-01", "2024-01-11", 10, []string{"20240101-20240110", "20240111-20240111"}},
+		{"2024-01-01", "2024-01-25", 10, []string{"20240101-20240110", "20240111-20240120", "20240121-20240125"}},
+		{"2024-02-20", "2024-03-05", 7, []string{"20240220-20240226", "20240227-20240304", "20240305-20240305"}}, // leap day
+		{"2024-01-10", "2024-01-01", 10, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, w := range splitRange(DateRange{From: date(tt.from), To: date(tt.to)}, tt.days) {
+			got = append(got, w.From.Format("20060102")+"-"+w.To.Format("20060102"))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitRange(%s..%s, %d) = %v, want %v", tt.from, tt.to, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRangeTruncatesToUTCDays(t *testing.T) {
+	// 23:30 in UTC-5 is already the next day in UTC
+	east := time.FixedZone("UTC-5", -5*3600)
+	r := DateRange{From: time.Date(2024, 1, 1, 23, 30, 0, 0, east), To: time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)}
+	windows := splitRange(r, 90)
+	if len(windows) != 1 || windows[0].From.Format("2006-01-02") != "2024-01-02" || windows[0].To.Format("2006-01-02") != "2024-01-03" {
+		t.Errorf("got %v, want one window 2024-01-02..2024-01-03", windows)
+	}
+}
+
+func TestHistoryURL(t *testing.T) {
+	p := &CMCScraperProvider{BaseURL: "https://coinmarketcap.com"}
+	r := DateRange{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)}
+	want := "https://coinmarketcap.com/currencies/ethereum/historical-data/?start=20240101&end=20240330"
+	if got := p.historyURL(TokenEntry{ScraperSlug: "ethereum"}, r); got != want {
+		t.Errorf("historyURL = %s, want %s", got, want)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	records := func(dates ...string) []HistoricalData {
+		var out []HistoricalData
+		for _, d := range dates {
+			out = append(out, HistoricalData{Date: d})
+		}
+		return out
+	}
+	r := DateRange{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name    string
+		records []HistoricalData
+		found   int
+		gaps    []string
+	}{
+		{"contiguous", records("2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05", "2024-01-06"), 6, nil},
+		{"nothing scraped", nil, 0, []string{"2024-01-01..2024-01-06"}},
+		{"a failed window in the middle", records("2024-01-01", "2024-01-02", "2024-01-05", "2024-01-06"), 4, []string{"2024-01-03..2024-01-04"}},
+		{"single days", records("2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"), 4, []string{"2024-01-01", "2024-01-06"}},
+		{"duplicates count once", records("2024-01-01", "2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05", "2024-01-06"), 6, nil},
+	}
+	for _, tt := range tests {
+		c := checkCoverage("eth", tt.records, r)
+		if c.Expected != 6 || c.Found != tt.found || !slices.Equal(c.Gaps(), tt.gaps) {
+			t.Errorf("%s: %d/%d days, gaps %v; want %d/6, gaps %v", tt.name, c.Found, c.Expected, c.Gaps(), tt.found, tt.gaps)
+		}
+	}
+}
+
+func TestCoverageLeavesOutToday(t *testing.T) {
+	today := dayOf(time.Now())
+	c := checkCoverage("eth", nil, DateRange{From: today.AddDate(0, 0, -2), To: today})
+	if c.Expected != 2 {
+		t.Errorf("expected %d days, want 2 (today is not closed yet)", c.Expected)
+	}
+}
+
+func TestStitchWindow(t *testing.T) {
+	seen := make(map[string]bool)
+	records, added := stitchWindow(nil, []HistoricalData{{Date: "2024-01-01", Close: 1}, {Date: "2024-01-02", Close: 2}}, seen)
+	if added != 2 {
+		t.Fatalf("first window added %d days, want 2", added)
+	}
+	// The next window repeats its first day
+	records, added = stitchWindow(records, []HistoricalData{{Date: "2024-01-02", Close: 20}, {Date: "2024-01-03", Close: 3}}, seen)
+	if added != 1 || len(records) != 3 || records[1].Close != 2 {
+		t.Errorf("added %d, records %+v; want 1 new day and the first window's 2024-01-02", added, records)
+	}
+}
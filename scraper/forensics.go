@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== FAILURE FORENSICS =====
+// Every run gets a directory under RUNS_DIR. When a page fails (load
+// timeout, no rows, nothing parsed) the scraper saves what the browser saw
+// into <run>/forensics/<page>/:
+//
+//	screenshot.png  full-page screenshot
+//	page.html       the rendered DOM
+//	console.log     console messages and page errors
+//	requests.log    every response and failed request
+//	trace.zip       Playwright trace (npx playwright show-trace trace.zip)
+//
+// and the run's summary.json lists each failure with its bundle.
+
+// RunSummary is written to <run>/summary.json when the run ends
+type RunSummary struct {
+	Command    string     `json:"command"`
+	StartedAt  string     `json:"started_at"`
+	FinishedAt string     `json:"finished_at"`
+	HARMode    string     `json:"har_mode,omitempty"`
+	Tokens     int        `json:"tokens"`
+	Records    int        `json:"records"`
+	Coverage   []Coverage `json:"coverage,omitempty"`
+	Failures   []Failure  `json:"failures"`
+	// Rows quarantined per broken rule (see quality.go)
+	Quarantined map[string]int `json:"quarantined,omitempty"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Failure is one page that produced nothing
+type Failure struct {
+	Token     string `json:"token"`
+	Page      string `json:"page"`
+	URL       string `json:"url"`
+	Error     string `json:"error"`
+	Forensics string `json:"forensics,omitempty"` // bundle directory
+}
+
+// startRun creates the run directory for a command
+func startRun(command string) *RunSummary {
+	started := time.Now()
+	run := &RunSummary{
+		Command:   command,
+		StartedAt: started.UTC().Format(time.RFC3339),
+		HARMode:   HAR_MODE,
+		Failures:  []Failure{},
+		dir:       filepath.Join(RUNS_DIR, started.Format("20060102-150405")+"-"+command),
+	}
+	if err := os.MkdirAll(run.dir, 0755); err != nil {
+		log.Printf("Could not create run directory %s: %v", run.dir, err)
+	}
+	return run
+}
+
+// Fail records a failed page; commands without a run (canary) skip it
+func (r *RunSummary) Fail(f Failure) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.Failures = append(r.Failures, f)
+	r.mu.Unlock()
+}
+
+// Finish writes summary.json and points at the bundles of failed pages
+func (r *RunSummary) Finish() {
+	r.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	path := filepath.Join(r.dir, "summary.json")
+	body, err := json.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, body, 0644)
+	}
+	if err != nil {
+		log.Printf("Could not write run summary %s: %v", path, err)
+		return
+	}
+
+	fmt.Printf("🧾 Run summary: %s\n", path)
+	if len(r.Failures) == 0 {
+		return
+	}
+	fmt.Printf("🧯 %d failed page(s), forensics:\n", len(r.Failures))
+	for _, f := range r.Failures {
+		fmt.Printf("   - %-20s %s\n", f.Page, f.Forensics)
+	}
+}
+
+// Forensics records what one page does so a failure can be saved as a
+// bundle. Listeners stay attached for the page's life; Begin and Discard
+// bracket each attempt.
+type Forensics struct {
+	ctx  playwright.BrowserContext
+	page playwright.Page
+
+	mu       sync.Mutex
+	console  []string
+	requests []string
+	tracing  bool
+}
+
+func attachForensics(ctx playwright.BrowserContext, page playwright.Page) *Forensics {
+	f := &Forensics{ctx: ctx, page: page}
+
+	stamp := func() string { return time.Now().Format("15:04:05.000") }
+	page.OnConsole(func(msg playwright.ConsoleMessage) {
+		f.add(&f.console, fmt.Sprintf("%s [%s] %s", stamp(), msg.Type(), msg.Text()))
+	})
+	page.OnPageError(func(err error) {
+		f.add(&f.console, fmt.Sprintf("%s [pageerror] %v", stamp(), err))
+	})
+	page.OnResponse(func(resp playwright.Response) {
+		f.add(&f.requests, fmt.Sprintf("%s %d %s %s", stamp(), resp.Status(), resp.Request().Method(), resp.URL()))
+	})
+	page.OnRequestFailed(func(req playwright.Request) {
+		f.add(&f.requests, fmt.Sprintf("%s FAILED %s %s: %v", stamp(), req.Method(), req.URL(), req.Failure()))
+	})
+	return f
+}
+
+func (f *Forensics) add(lines *[]string, line string) {
+	f.mu.Lock()
+	*lines = append(*lines, line)
+	f.mu.Unlock()
+}
+
+// Begin clears the logs and starts a trace for the next attempt
+func (f *Forensics) Begin() {
+	f.mu.Lock()
+	f.console, f.requests = nil, nil
+	f.mu.Unlock()
+
+	if f.tracing {
+		f.ctx.Tracing().Stop()
+	}
+	err := f.ctx.Tracing().Start(playwright.TracingStartOptions{
+		Screenshots: playwright.Bool(true),
+		Snapshots:   playwright.Bool(true),
+	})
+	f.tracing = err == nil
+	if err != nil {
+		log.Printf("Could not start trace: %v", err)
+	}
+}
+
+// Discard ends a successful attempt without keeping anything
+func (f *Forensics) Discard() {
+	if f.tracing {
+		f.ctx.Tracing().Stop()
+		f.tracing = false
+	}
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Save writes the bundle for a failed attempt and returns its directory.
+// Each artifact is best effort: a crashed page still leaves the logs.
+func (f *Forensics) Save(run *RunSummary, name string, cause error) string {
+	if run == nil {
+		f.Discard()
+		return ""
+	}
+	dir := filepath.Join(run.dir, "forensics", unsafeName.ReplaceAllString(name, "_"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Could not create forensics directory %s: %v", dir, err)
+		return ""
+	}
+
+	_, err := f.page.Screenshot(playwright.PageScreenshotOptions{
+		Path:     playwright.String(filepath.Join(dir, "screenshot.png")),
+		FullPage: playwright.Bool(true),
+		Timeout:  playwright.Float(10000),
+	})
+	if err != nil {
+		log.Printf("Forensics %s: screenshot: %v", name, err)
+	}
+
+	if html, err := f.page.Content(); err == nil {
+		os.WriteFile(filepath.Join(dir, "page.html"), []byte(html), 0644)
+	} else {
+		log.Printf("Forensics %s: page content: %v", name, err)
+	}
+
+	f.mu.Lock()
+	console := append([]string{fmt.Sprintf("# %s at %s: %v", f.page.URL(), time.Now().Format(time.RFC3339), cause)}, f.console...)
+	requests := f.requests
+	f.mu.Unlock()
+	os.WriteFile(filepath.Join(dir, "console.log"), []byte(strings.Join(console, "\n")+"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "requests.log"), []byte(strings.Join(requests, "\n")+"\n"), 0644)
+
+	if f.tracing {
+		if err := f.ctx.Tracing().Stop(filepath.Join(dir, "trace.zip")); err != nil {
+			log.Printf("Forensics %s: trace: %v", name, err)
+		}
+		f.tracing = false
+	}
+	return dir
+}
@@ -2,7 +2,10 @@ module github.com/R-Abinav/SafeSwap.ai/scraper
 
 go 1.25.3
 
-require github.com/playwright-community/playwright-go v0.5200.1
+require (
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/playwright-community/playwright-go v0.5200.1
+)
 
 require (
 	github.com/deckarep/golang-set/v2 v2.7.0 // indirect
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== HAR RECORD / REPLAY =====
+// `--record` saves every page's traffic to HAR_DIR/<name>.har and
+// `--replay` serves those archives back through Playwright's HAR routing, so
+// a run touches no network and parses exactly the captured pages. Both modes
+// give each token a fresh browser context: Playwright only writes the archive
+// when the context closes, and replay routes must not leak between tokens.
+
+const (
+	HAR_RECORD = "record"
+	HAR_REPLAY = "replay"
+)
+
+// parseHARFlags reads --record / --replay for a scraping command. Replayed
+// runs write next to the real outputs, never over them.
+func parseHARFlags(cmd string, args []string) {
+	fs := flag.NewFlagSet(strings.TrimSpace("scraper "+cmd), flag.ExitOnError)
+	record := fs.Bool("record", false, "save each page's traffic to <har-dir>/<name>.har")
+	replay := fs.Bool("replay", false, "scrape the recorded pages offline instead of the live site")
+	fs.StringVar(&HAR_DIR, "har-dir", HAR_DIR, "directory of HAR archives")
+	fs.Parse(args)
+
+	switch {
+	case *record && *replay:
+		fmt.Println("❌ --record and --replay cannot be combined")
+		os.Exit(2)
+	case *record:
+		HAR_MODE = HAR_RECORD
+	case *replay:
+		HAR_MODE = HAR_REPLAY
+		CMC_CSV_PATH = replayPath(CMC_CSV_PATH)
+		CG_SCRAPER_CSV_PATH = replayPath(CG_SCRAPER_CSV_PATH)
+		SQLITE_PATH = replayPath(SQLITE_PATH)
+		QUARANTINE_PATH = replayPath(QUARANTINE_PATH)
+	}
+}
+
+// printHARMode announces the HAR mode, creating the directory to record to
+func printHARMode() {
+	switch HAR_MODE {
+	case HAR_RECORD:
+		if err := os.MkdirAll(HAR_DIR, 0755); err != nil {
+			log.Fatalf("Failed to create HAR directory: %v", err)
+		}
+		fmt.Printf("🎞️  Recording pages to: %s\n", HAR_DIR)
+	case HAR_REPLAY:
+		fmt.Printf("📼 Replaying pages from: %s (offline)\n", HAR_DIR)
+	}
+}
+
+// harPath is the archive for one scraped page: the CMC slug, or
+// "coingecko-<id>" for CoinGecko pages
+func harPath(name string) string {
+	return filepath.Join(HAR_DIR, name+".har")
+}
+
+// replayPath is where a replayed run writes instead of path, so replays never
+// overwrite the real dataset
+func replayPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_replay" + ext
+}
+
+// newHARContext opens a context that records to, or replays from, the
+// named archive
+func newHARContext(browser playwright.Browser, name string) (playwright.BrowserContext, error) {
+	path := harPath(name)
+
+	switch HAR_MODE {
+	case HAR_RECORD:
+		ctx, _, err := newIdentityContext(browser, func(opts *playwright.BrowserNewContextOptions) {
+			opts.RecordHarPath = playwright.String(path)
+			opts.RecordHarContent = playwright.HarContentPolicyEmbed
+			opts.RecordHarMode = playwright.HarModeFull
+		})
+		return ctx, err
+	case HAR_REPLAY:
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("no recording for %s: %w", name, err)
+		}
+		ctx, _, err := newIdentityContext(browser, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Anything the recording does not have fails instead of going online
+		err = ctx.RouteFromHAR(path, playwright.BrowserContextRouteFromHAROptions{
+			NotFound: playwright.HarNotFoundAbort,
+		})
+		if err != nil {
+			ctx.Close()
+			return nil, fmt.Errorf("could not route from %s: %w", path, err)
+		}
+		return ctx, nil
+	}
+	return nil, fmt.Errorf("unknown HAR mode %q", HAR_MODE)
+}
+
+// recordedPageURLs returns the historical-data URLs the token was recorded
+// with, one per window. HAR routing matches on the full URL, so a replay has
+// to request the captured date ranges rather than ones computed from today.
+func recordedPageURLs(slug string) ([]string, error) {
+	body, err := os.ReadFile(harPath(slug))
+	if err != nil {
+		return nil, err
+	}
+
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					Method string `json:"method"`
+					URL    string `json:"url"`
+				} `json:"request"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(body, &har); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", harPath(slug), err)
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	marker := "/currencies/" + slug + "/historical-data/"
+	for _, e := range har.Log.Entries {
+		url := e.Request.URL
+		if e.Request.Method == "GET" && strings.Contains(url, marker) && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("%s has no historical-data page", harPath(slug))
+	}
+	return urls, nil
+}
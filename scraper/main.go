@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/csv"
 	"fmt"
 	"log"
 	"os"
@@ -9,33 +8,69 @@ import (
 	"strings"
 	"time"
 
+	"github.com/R-Abinav/SafeSwap.ai/scraper/numparse"
 	"github.com/playwright-community/playwright-go"
 )
 
 // ===== CONFIGURATION =====
 var (
 	// File paths
-	CMC_CSV_PATH = "./data/crypto_data_coinmarketcap.csv"
-	LOG_PATH     = "./data/scraper.log"
-
-	// Tokens to scrape (CoinMarketCap slugs)
-	TOKENS = []string{
-		"bitcoin", "ethereum", "solana", "cardano", "ripple",
-		"polkadot", "dogecoin", "avalanche-2012", "chainlink", "polygon",
-		"uniswap", "litecoin", "stellar", "cosmos", "monero",
-		"tron", "ethereum-classic", "filecoin", "internet-computer", "aptos",
-		"shiba-inu", "wrapped-bitcoin", "dai", "leo-token", "toncoin",
-	}
+	CMC_CSV_PATH        = "./data/crypto_data_coinmarketcap.csv"
+	CG_SCRAPER_CSV_PATH = "./data/crypto_data_coingecko_scraper.csv"
+	LOG_PATH            = "./data/scraper.log"
+
+	// Storage backend: csv (file above) or sqlite (see store.go)
+	STORE_BACKEND = envOr("STORE_BACKEND", STORE_CSV)
+	SQLITE_PATH   = envOr("SQLITE_PATH", "./data/market_data.db")
+	STORE         Store
+
+	// Token registry shared with the API collector
+	TOKEN_REGISTRY_PATH = envOr("TOKEN_REGISTRY_PATH", "../tokens.json")
+	TOKEN_REGISTRY      *TokenRegistry
+
+	// Resolved symbols, names and ids, written by the API collector
+	METADATA_PATH = envOr("METADATA_CACHE_PATH", "../token_metadata.json")
+	METADATA      *MetadataCache
+
+	// Tokens to scrape (those with a CoinMarketCap slug), loaded from the registry
+	TOKENS []TokenEntry
 
 	// Historical data range (in days)
 	DAYS_HISTORICAL = 365 // Get 1 year of data
 
-	// Scraping delay (to avoid rate limiting)
+	// Days per history page load; longer ranges are split (see coverage.go)
+	SCRAPE_WINDOW_DAYS = envInt("SCRAPE_WINDOW_DAYS", 90)
+
+	// "Load More" clicks / scrolls per page before giving up on the table
+	MAX_LOAD_ROUNDS = 50
+
+	// Minimum gap between page loads across all workers (to avoid rate limiting)
 	SCRAPE_DELAY = 3 * time.Second
+
+	// Browser contexts scraping in parallel
+	SCRAPE_WORKERS = envInt("SCRAPE_WORKERS", 4)
+
+	// User agents, viewports, proxies, locale and cookies (see browser.go)
+	BROWSER_CONFIG_PATH = envOr("BROWSER_CONFIG_PATH", "./browser.json")
+	BROWSER_CONFIG      *BrowserConfig
+
+	// Page traffic archives for --record / --replay (see har.go)
+	HAR_DIR  = envOr("HAR_DIR", "./data/har")
+	HAR_MODE string
+
+	// Per-run summaries and failure forensics (see forensics.go)
+	RUNS_DIR = envOr("RUNS_DIR", "./data/runs")
+	RUN      *RunSummary
+
+	// Rows that fail the data quality checks (see quality.go)
+	QUARANTINE_PATH = envOr("QUARANTINE_PATH", "./data/quarantine.csv")
+	QUARANTINE      *Quarantine
 )
 
 // ===== DATA STRUCTURES =====
 type HistoricalData struct {
+	TokenKey          string // registry key (not written to the CSV)
+	CMCID             int    // numeric CoinMarketCap id (not written to the CSV)
 	Date              string
 	TokenSymbol       string
 	TokenName         string
@@ -49,39 +84,58 @@ type HistoricalData struct {
 }
 
 func main() {
-	startTime := time.Now()
+	cmd, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "canary":
+		os.Exit(runCanary(args))
+	case "", "coingecko":
+	default:
+		fmt.Printf("Unknown command: %s\n", cmd)
+		fmt.Println("Usage: go run . [coingecko] [--record|--replay] [--har-dir dir] | canary [--token key]")
+		os.Exit(2)
+	}
+	parseHARFlags(cmd, args)
 
-	// Setup logging
-	os.MkdirAll("./data", 0755)
-	logFile, err := os.OpenFile(LOG_PATH, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+	if cmd == "coingecko" {
+		os.Exit(runCoinGecko())
 	}
+
+	startTime := time.Now()
+
+	logFile := setup()
 	defer logFile.Close()
-	log.SetOutput(logFile)
+	if !TOKEN_REGISTRY.Enabled(SOURCE_CMC_SCRAPER) {
+		fmt.Println("⏭️  CMC scraper disabled in token registry")
+		return
+	}
+
+	var unmapped []string
+	TOKENS, unmapped = TOKEN_REGISTRY.ScraperTokens()
+	for _, key := range unmapped {
+		log.Printf("Token %s has no scraper_slug, skipping", key)
+		fmt.Printf("⚠️  %s has no scraper slug, skipping\n", key)
+	}
 
 	fmt.Println("╔════════════════════════════════════════════════════╗")
 	fmt.Println("║   COINMARKETCAP HISTORICAL DATA SCRAPER v2.0      ║")
 	fmt.Println("╚════════════════════════════════════════════════════╝")
 	fmt.Printf("\n📊 Collecting historical data for %d tokens\n", len(TOKENS))
-	fmt.Printf("📁 Output file: %s\n", CMC_CSV_PATH)
-	fmt.Printf("📅 Historical days: %d\n", DAYS_HISTORICAL)
-	fmt.Printf("⏱️  Delay between tokens: %ds\n\n", int(SCRAPE_DELAY.Seconds()))
-
-	// Initialize CSV file
-	fmt.Println("🔧 Initializing CSV file...")
-	if err := initCSV(CMC_CSV_PATH); err != nil {
-		log.Fatalf("Failed to init CSV: %v", err)
-	}
-	fmt.Println("✅ CSV file initialized")
+	fmt.Printf("📁 Output: %s\n", outputPath())
+	fmt.Printf("📅 Historical days: %d (%d-day windows)\n", DAYS_HISTORICAL, SCRAPE_WINDOW_DAYS)
+	fmt.Printf("👷 Workers: %d\n", SCRAPE_WORKERS)
+	fmt.Printf("⏱️  Delay between page loads: %ds\n", int(SCRAPE_DELAY.Seconds()))
+	printHARMode()
+	fmt.Println()
 
-	// Install Playwright (only needed first time)
-	fmt.Println("🎭 Initializing Playwright...")
-	err = playwright.Install()
-	if err != nil {
-		log.Fatalf("Could not install playwright: %v", err)
-	}
-	fmt.Println("✅ Playwright ready")
+	// Open the output store
+	STORE = openStore()
+	defer STORE.Close()
+	RUN = startRun("cmc")
+
+	installPlaywright()
 
 	// Start scraping
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -96,197 +150,154 @@ func main() {
 	fmt.Println("╚════════════════════════════════════════════════════╝")
 	fmt.Printf("📊 Total records collected: %d\n", totalRecords)
 	fmt.Printf("⏱️  Total time: %v\n", elapsed.Round(time.Second))
-	fmt.Printf("📁 Data saved to: %s\n", CMC_CSV_PATH)
+	fmt.Printf("📁 Data saved to: %s\n", outputPath())
 	fmt.Printf("📈 Average: %.1f records per token\n", float64(totalRecords)/float64(len(TOKENS)))
+
+	RUN.Tokens, RUN.Records = len(TOKENS), totalRecords
+	RUN.Finish()
 }
 
-func initCSV(filepath string) error {
-	file, err := os.Create(filepath)
+// setup opens the log file and loads the token registry and metadata cache
+func setup() *os.File {
+	os.MkdirAll("./data", 0755)
+	logFile, err := os.OpenFile(LOG_PATH, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	headers := []string{
-		"date",
-		"token_symbol",
-		"token_name",
-		"open",
-		"high",
-		"low",
-		"close",
-		"volume",
-		"market_cap",
-		"source",
+		log.Fatalf("Failed to open log file: %v", err)
 	}
+	log.SetOutput(logFile)
+	QUARANTINE = NewQuarantine(QUARANTINE_PATH)
 
-	return writer.Write(headers)
+	TOKEN_REGISTRY, err = loadTokenRegistry(TOKEN_REGISTRY_PATH)
+	if err != nil {
+		log.Fatalf("Failed to load token registry: %v", err)
+	}
+	METADATA, err = loadMetadataCache(METADATA_PATH)
+	if err != nil {
+		log.Printf("Could not load metadata cache, using registry names: %v", err)
+	}
+	BROWSER_CONFIG, err = loadBrowserConfig(BROWSER_CONFIG_PATH)
+	if err != nil {
+		log.Fatalf("Failed to load browser config: %v", err)
+	}
+	return logFile
 }
 
-func scrapeHistoricalData() int {
-	totalRecords := 0
+// installPlaywright fetches the driver and browsers (only needed first time)
+func installPlaywright() {
+	fmt.Println("🎭 Initializing Playwright...")
+	if err := playwright.Install(); err != nil {
+		log.Fatalf("Could not install playwright: %v", err)
+	}
+	fmt.Println("✅ Playwright ready")
+}
 
-	// Start Playwright
+// startBrowser launches the headless browser all contexts are opened on
+func startBrowser() (*playwright.Playwright, playwright.Browser) {
 	pw, err := playwright.Run()
 	if err != nil {
 		log.Fatalf("Could not start playwright: %v", err)
 	}
-	defer pw.Stop()
 
-	// Launch browser once for all tokens (more efficient)
 	fmt.Println("🌐 Launching headless browser...")
-	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
-		Headless: playwright.Bool(true),
-	})
+	fmt.Printf("🪪 Browser identity: %s\n", BROWSER_CONFIG.describe())
+	browser, err := pw.Chromium.Launch(BROWSER_CONFIG.LaunchOptions())
 	if err != nil {
+		pw.Stop()
 		log.Fatalf("Could not launch browser: %v", err)
 	}
-	defer browser.Close()
 	fmt.Println("✅ Browser launched")
+	return pw, browser
+}
 
-	// Calculate date range
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -DAYS_HISTORICAL)
-	startStr := startDate.Format("20060102")
-	endStr := endDate.Format("20060102")
-
-	// Scrape each token
-	for i, token := range TOKENS {
-		fmt.Printf("\n[%d/%d] 🔍 Scraping %s...\n", i+1, len(TOKENS), strings.ToUpper(token))
-		
-		records := scrapeToken(browser, token, startStr, endStr)
-		
-		if len(records) > 0 {
-			if err := appendToCSV(CMC_CSV_PATH, records); err != nil {
-				log.Printf("Error writing data for %s: %v", token, err)
-				fmt.Printf("  ❌ Error writing to CSV\n")
-			} else {
-				totalRecords += len(records)
-				fmt.Printf("  ✅ Collected %d records\n", len(records))
-			}
-		} else {
-			fmt.Printf("  ⚠️  No data collected\n")
-		}
-
-		// Rate limiting delay (except for last token)
-		if i < len(TOKENS)-1 {
-			fmt.Printf("  ⏳ Waiting %ds...\n", int(SCRAPE_DELAY.Seconds()))
-			time.Sleep(SCRAPE_DELAY)
-		}
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-
-	return totalRecords
+	return fallback
 }
 
-func scrapeToken(browser playwright.Browser, token, startDate, endDate string) []HistoricalData {
-	var records []HistoricalData
-
-	// Create a new page
-	page, err := browser.NewPage()
-	if err != nil {
-		log.Printf("Could not create page: %v", err)
-		return records
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
 	}
-	defer page.Close()
+	return fallback
+}
 
-	// Build URL
-	url := fmt.Sprintf("https://coinmarketcap.com/currencies/%s/historical-data/?start=%s&end=%s",
-		token, startDate, endDate)
+func scrapeHistoricalData() int {
+	totalRecords := 0
+
+	// Launch browser once for all tokens (more efficient)
+	pw, browser := startBrowser()
+	defer pw.Stop()
+	defer browser.Close()
 
-	// Navigate to the page
-	_, err = page.Goto(url, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateNetworkidle,
-		Timeout:   playwright.Float(45000), // 45 second timeout
-	})
+	pool, err := NewContextPool(browser, SCRAPE_WORKERS)
 	if err != nil {
-		log.Printf("Could not goto page for %s: %v", token, err)
-		fmt.Printf("  ⚠️  Page load timeout\n")
-		return records
+		log.Fatalf("Could not create browser contexts: %v", err)
 	}
+	defer pool.Close()
 
-	// Wait a bit for content to load
-	time.Sleep(2 * time.Second)
+	PROVIDERS = []MarketDataProvider{NewCMCScraperProvider(pool, NewPoliteness(SCRAPE_DELAY))}
 
-	// Try to find the table
-	rows, err := page.Locator("table tbody tr").All()
-	if err != nil || len(rows) == 0 {
-		log.Printf("No table rows found for %s", token)
-		return records
-	}
-
-	fmt.Printf("  📊 Found %d rows\n", len(rows))
+	// Calculate date range
+	endDate := time.Now()
+	startDate := endDate.AddDate(0, 0, -DAYS_HISTORICAL)
+	dateRange := DateRange{From: startDate, To: endDate}
 
-	// Extract data from each row
-	for _, row := range rows {
-		cells, err := row.Locator("td").All()
-		if err != nil || len(cells) < 7 {
+	var coverage []Coverage
+	for _, p := range PROVIDERS {
+		if !p.Capabilities().Historical {
 			continue
 		}
 
-		data := HistoricalData{
-			TokenSymbol: strings.ToUpper(token),
-			TokenName:   "",
-			Source:      "CoinMarketCap",
-		}
+		// Scrape tokens in parallel; rows are written in registry order
+		scrapeConcurrently(p, TOKENS, dateRange, SCRAPE_WORKERS, func(res tokenResult) {
+			slug := strings.ToUpper(res.token.ScraperSlug)
 
-		// Extract date (column 0)
-		dateText, _ := cells[0].TextContent()
-		data.Date = parseDate(strings.TrimSpace(dateText))
-
-		// Extract OHLC and Volume
-		data.Open = parsePrice(cells, 1)
-		data.High = parsePrice(cells, 2)
-		data.Low = parsePrice(cells, 3)
-		data.Close = parsePrice(cells, 4)
-		data.Volume = parsePrice(cells, 5)
-		data.MarketCap = parsePrice(cells, 6)
-
-		// Only add if we have valid data
-		if data.Date != "" && data.Close > 0 {
-			records = append(records, data)
-		}
+			// Replays cover whatever was recorded, not today's range
+			want := dateRange
+			if HAR_MODE == HAR_REPLAY {
+				want = recordSpan(res.records)
+			}
+			res.records = QUARANTINE.validRecords(p.Name(), want, res.records)
+			c := checkCoverage(res.token.ScraperSlug, res.records, want)
+			coverage = append(coverage, c)
+
+			if len(res.records) == 0 {
+				fmt.Printf("  ⚠️  %s: no data collected\n", slug)
+				return
+			}
+			stats, err := STORE.WriteHistorical(p.Name(), res.records)
+			if err != nil {
+				log.Printf("Error writing data for %s: %v", res.token.ScraperSlug, err)
+				fmt.Printf("  ❌ %s: error writing data\n", slug)
+				return
+			}
+			totalRecords += len(res.records)
+			fmt.Printf("  ✅ %s: collected %d records (%s)\n", slug, len(res.records), stats)
+			printCoverage(c)
+		})
 	}
 
-	return records
+	printCoverageReport(coverage)
+	RUN.Coverage = coverage
+	RUN.Quarantined = QUARANTINE.Rules()
+	QUARANTINE.Report()
+	return totalRecords
 }
 
-func parsePrice(cells []playwright.Locator, index int) float64 {
+// parseCell reads a numeric table cell, see the numparse package
+func parseCell(cells []playwright.Locator, index int) (float64, error) {
 	if index >= len(cells) {
-		return 0
+		return 0, fmt.Errorf("no cell %d", index)
 	}
 
 	text, err := cells[index].TextContent()
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	// Clean the string
-	text = strings.TrimSpace(text)
-	text = strings.ReplaceAll(text, "$", "")
-	text = strings.ReplaceAll(text, ",", "")
-
-	// Handle billions/millions
-	multiplier := 1.0
-	if strings.Contains(text, "B") || strings.Contains(text, "b") {
-		multiplier = 1e9
-		text = strings.ReplaceAll(text, "B", "")
-		text = strings.ReplaceAll(text, "b", "")
-	} else if strings.Contains(text, "M") || strings.Contains(text, "m") {
-		multiplier = 1e6
-		text = strings.ReplaceAll(text, "M", "")
-		text = strings.ReplaceAll(text, "m", "")
-	}
-
-	text = strings.TrimSpace(text)
-
-	if val, err := strconv.ParseFloat(text, 64); err == nil {
-		return val * multiplier
-	}
-
-	return 0
+	return numparse.Parse(text)
 }
 
 func parseDate(dateStr string) string {
@@ -306,34 +317,3 @@ func parseDate(dateStr string) string {
 
 	return dateStr
 }
-
-func appendToCSV(filepath string, data []HistoricalData) error {
-	file, err := os.OpenFile(filepath, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, d := range data {
-		record := []string{
-			d.Date,
-			d.TokenSymbol,
-			d.TokenName,
-			fmt.Sprintf("%.8f", d.Open),
-			fmt.Sprintf("%.8f", d.High),
-			fmt.Sprintf("%.8f", d.Low),
-			fmt.Sprintf("%.8f", d.Close),
-			fmt.Sprintf("%.2f", d.Volume),
-			fmt.Sprintf("%.2f", d.MarketCap),
-			d.Source,
-		}
-		if err := writer.Write(record); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
\ No newline at end of file
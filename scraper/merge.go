@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ===== HISTORY MERGE =====
+// The CMC history CSV is never started afresh. Each token's rows are merged
+// into what is already there, keyed by date and token: new days are
+// appended, a day is replaced only when a value changed, and everything
+// else is left as it was. The file is rewritten through a temp file and a
+// rename, and the first write of a run keeps the previous version as
+// <path>.bak.
+
+var HISTORY_CSV_HEADER = CMC_SCRAPER_DATASET.Current().Columns
+
+// MergeStats counts what a write did to the stored history
+type MergeStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+}
+
+func (m MergeStats) String() string {
+	return fmt.Sprintf("%d new, %d updated, %d unchanged", m.Added, m.Updated, m.Unchanged)
+}
+
+// historyRecord formats a record as a CSV row
+func historyRecord(d HistoricalData) []string {
+	return []string{
+		d.Date,
+		d.TokenSymbol,
+		d.TokenName,
+		fmt.Sprintf("%.8f", d.Open),
+		fmt.Sprintf("%.8f", d.High),
+		fmt.Sprintf("%.8f", d.Low),
+		fmt.Sprintf("%.8f", d.Close),
+		fmt.Sprintf("%.2f", d.Volume),
+		fmt.Sprintf("%.2f", d.MarketCap),
+		d.Source,
+	}
+}
+
+// readHistoryCSV returns the rows of an existing history file without its
+// header; a missing file has none. A file in an older schema version is
+// upgraded first (schema.go).
+func readHistoryCSV(path string) ([][]string, error) {
+	if _, err := ensureSchema(path, &CMC_SCRAPER_DATASET); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Refuse to rewrite a file we do not understand
+	if !slices.Equal(header, HISTORY_CSV_HEADER) {
+		return nil, fmt.Errorf("%s is not in the current %s schema (header: %s)",
+			path, CMC_SCRAPER_DATASET.Name, strings.Join(header, ","))
+	}
+	return reader.ReadAll()
+}
+
+// rowToken names the token of a stored row. Older files hold the upper-cased
+// slug in token_symbol, so the symbol is matched against the registry's
+// keys and slugs as well as the resolved symbols.
+func rowToken(symbol string) string {
+	if TOKEN_REGISTRY != nil {
+		for _, t := range TOKEN_REGISTRY.Tokens {
+			if strings.EqualFold(symbol, t.Key) || strings.EqualFold(symbol, t.ScraperSlug) ||
+				strings.EqualFold(symbol, METADATA.Resolve(t).Symbol) {
+				return t.Key
+			}
+		}
+	}
+	return strings.ToLower(symbol)
+}
+
+// mergeHistory folds records into rows. Stored rows keep their order; new
+// days are appended in the order given.
+func mergeHistory(rows [][]string, records []HistoricalData) ([][]string, MergeStats) {
+	var stats MergeStats
+
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		index[rowToken(row[1])+"|"+row[0]] = i
+	}
+
+	for _, d := range records {
+		token := d.TokenKey
+		if token == "" {
+			token = rowToken(d.TokenSymbol)
+		}
+		record := historyRecord(d)
+		key := token + "|" + d.Date
+
+		i, ok := index[key]
+		switch {
+		case !ok:
+			index[key] = len(rows)
+			rows = append(rows, record)
+			stats.Added++
+		case slices.Equal(rows[i], record):
+			stats.Unchanged++
+		default:
+			rows[i] = record
+			stats.Updated++
+		}
+	}
+	return rows, stats
+}
+
+// writeCSVAtomic replaces path with header and rows in one rename
+func writeCSVAtomic(path string, header []string, rows [][]string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	writer := csv.NewWriter(tmp)
+	if err := writer.Write(header); err != nil {
+		return fail(err)
+	}
+	// WriteAll flushes and returns the first write error
+	if err := writer.WriteAll(rows); err != nil {
+		return fail(err)
+	}
+	// The rename must not land before the data does
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupFile copies path to path.bak; there is nothing to keep if path does
+// not exist yet
+func backupFile(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".bak")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMergeHistory(t *testing.T) {
+	day := func(date string, close float64) HistoricalData {
+		return HistoricalData{TokenKey: "btc", Date: date, TokenSymbol: "BTC", TokenName: "Bitcoin",
+			Open: 1, High: 2, Low: 0.5, Close: close, Source: "cmc"}
+	}
+	rows := [][]string{historyRecord(day("2024-01-01", 1)), historyRecord(day("2024-01-02", 1))}
+
+	merged, stats := mergeHistory(rows, []HistoricalData{
+		day("2024-01-01", 1),   // unchanged
+		day("2024-01-02", 1.5), // corrected close
+		day("2024-01-03", 2),   // new day
+	})
+	if stats != (MergeStats{Added: 1, Updated: 1, Unchanged: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(merged) != 3 || merged[1][6] != "1.50000000" || merged[2][0] != "2024-01-03" {
+		t.Errorf("merged rows = %v", merged)
+	}
+}
+
+func TestWriteCSVAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.csv")
+	rows := [][]string{historyRecord(HistoricalData{Date: "2024-01-01", TokenSymbol: "BTC"})}
+
+	for range 2 { // the second write replaces the first
+		if err := writeCSVAtomic(path, HISTORY_CSV_HEADER, rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := readHistoryCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !slices.Equal(got[0], rows[0]) {
+		t.Errorf("read back %v, want %v", got, rows)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
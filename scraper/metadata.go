@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ===== TOKEN METADATA =====
+// Symbols, names and CMC ids resolved by the API collector (`go run .
+// metadata` in ../api) and cached in ../token_metadata.json. The scraper
+// only reads the cache; without it the registry's values are used. The CMC id
+// is stored in the SQLite tables only, the CSV layouts have no column for it.
+
+type TokenMetadata struct {
+	Symbol      string `json:"symbol"`
+	Name        string `json:"name"`
+	CMCID       int    `json:"cmc_id,omitempty"`
+	CMCSlug     string `json:"cmc_slug,omitempty"`
+	CoinGeckoID string `json:"coingecko_id,omitempty"`
+}
+
+type MetadataCache struct {
+	UpdatedAt string                   `json:"updated_at"`
+	Tokens    map[string]TokenMetadata `json:"tokens"` // registry key -> metadata
+}
+
+func loadMetadataCache(path string) (*MetadataCache, error) {
+	cache := &MetadataCache{Tokens: make(map[string]TokenMetadata)}
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, cache); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cache.Tokens == nil {
+		cache.Tokens = make(map[string]TokenMetadata)
+	}
+	return cache, nil
+}
+
+// Resolve returns the token's metadata: cached values where known, registry
+// values otherwise
+func (c *MetadataCache) Resolve(token TokenEntry) TokenMetadata {
+	meta := TokenMetadata{
+		Symbol:      strings.ToUpper(token.Symbol),
+		Name:        token.Name,
+		CMCID:       token.CMC.ID,
+		CMCSlug:     token.CMC.Slug,
+		CoinGeckoID: token.CoinGeckoID,
+	}
+	if c == nil {
+		return meta
+	}
+
+	cached, ok := c.Tokens[token.Key]
+	if !ok {
+		return meta
+	}
+	if cached.Symbol != "" {
+		meta.Symbol = cached.Symbol
+	}
+	if cached.Name != "" {
+		meta.Name = cached.Name
+	}
+	if cached.CMCID != 0 {
+		meta.CMCID = cached.CMCID
+	}
+	if cached.CMCSlug != "" {
+		meta.CMCSlug = cached.CMCSlug
+	}
+	if cached.CoinGeckoID != "" {
+		meta.CoinGeckoID = cached.CoinGeckoID
+	}
+	return meta
+}
@@ -0,0 +1,310 @@
+// Package numparse turns the number strings shown on market data pages into
+// float64s. It understands currency symbols, K/M/B/T magnitude suffixes,
+// "<$0.01"-style bounds, unicode minus signs, parenthesised negatives,
+// CoinMarketCap's subscript-zero notation for tiny prices ($0.0₅123 is
+// 0.00000123) and locale-specific separators.
+//
+// Unlike the scraper's old parsePrice it never returns a silent zero: a cell
+// that cannot be read is an error, and a placeholder such as "--" or "N/A"
+// is ErrEmpty so callers can tell "missing" from "malformed".
+package numparse
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrEmpty is returned for blank cells and placeholders ("--", "N/A")
+	ErrEmpty = errors.New("empty value")
+	// ErrInvalid is returned for anything that is not a number
+	ErrInvalid = errors.New("invalid number")
+)
+
+// Locale describes the decimal and digit-group separators of a format
+type Locale struct {
+	Decimal rune
+	Group   rune
+}
+
+var (
+	LocaleUS    = Locale{Decimal: '.', Group: ','}      // 1,234.56
+	LocaleEU    = Locale{Decimal: ',', Group: '.'}      // 1.234,56
+	LocaleFR    = Locale{Decimal: ',', Group: '\u202f'} // 1 234,56 (narrow no-break space)
+	LocaleSwiss = Locale{Decimal: '.', Group: '\''}     // 1'234.56
+)
+
+// Bound says whether the page showed an exact value or only a limit
+type Bound int
+
+const (
+	Exact Bound = iota
+	Below       // "<$0.01"
+	Above       // ">$1T"
+)
+
+// Number is a parsed value and whether it was exact
+type Number struct {
+	Value float64
+	Bound Bound
+}
+
+var placeholders = map[string]bool{
+	"-": true, "--": true, "---": true, "—": true, "–": true,
+	"n/a": true, "na": true, "none": true, "null": true, "?": true,
+}
+
+// Currency prefixes/suffixes, longest first so "US$" wins over "$"
+var currencies = []string{"USDT", "US$", "USD", "EUR", "GBP", "JPY", "$", "€", "£", "¥", "₹", "₩", "₽", "₺", "₿"}
+
+var suffixes = map[string]float64{
+	"k": 1e3, "thousand": 1e3,
+	"m": 1e6, "mn": 1e6, "mm": 1e6, "million": 1e6,
+	"b": 1e9, "bn": 1e9, "billion": 1e9,
+	"t": 1e12, "tn": 1e12, "trillion": 1e12,
+}
+
+// Parse reads a US-formatted cell and returns its value; bounds such as
+// "<$0.01" return the limit
+func Parse(s string) (float64, error) {
+	n, err := ParseNumber(s, LocaleUS)
+	return n.Value, err
+}
+
+// ParseNumber reads a cell written in the given locale
+func ParseNumber(s string, loc Locale) (Number, error) {
+	var n Number
+	text := normalizeSpaces(s)
+
+	if text == "" || placeholders[strings.ToLower(text)] {
+		return n, ErrEmpty
+	}
+
+	// Bound markers come first: "<$0.01", "≈ 1.2K"
+	switch {
+	case strings.HasPrefix(text, "<"), strings.HasPrefix(text, "≤"):
+		n.Bound = Below
+		text = trimFirstRune(text)
+	case strings.HasPrefix(text, ">"), strings.HasPrefix(text, "≥"):
+		n.Bound = Above
+		text = trimFirstRune(text)
+	case strings.HasPrefix(text, "~"), strings.HasPrefix(text, "≈"):
+		text = trimFirstRune(text)
+	}
+	text = strings.TrimSpace(text)
+
+	negative := false
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		negative = true
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+
+	// Sign and currency can come in either order: "-$1.2", "$-1.2", "1.2 €"
+	for changed := true; changed; {
+		changed = false
+		if sign, rest, ok := cutSign(text); ok {
+			if sign < 0 {
+				negative = !negative
+			}
+			text, changed = rest, true
+		}
+		if rest, ok := cutCurrency(text); ok {
+			text, changed = rest, true
+		}
+		text = strings.TrimSpace(text)
+	}
+
+	text = strings.TrimSuffix(text, "%")
+	text = strings.TrimSpace(text)
+
+	text, multiplier := cutSuffix(text)
+
+	text, err := expandSubscriptZeros(text)
+	if err != nil {
+		return n, fmt.Errorf("%w: %q: %v", ErrInvalid, s, err)
+	}
+
+	text, err = applyLocale(text, loc)
+	if err != nil {
+		return n, fmt.Errorf("%w: %q: %v", ErrInvalid, s, err)
+	}
+	if text == "" {
+		return n, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	for _, r := range text {
+		if !(r >= '0' && r <= '9') && r != '.' && r != 'e' && r != 'E' && r != '-' && r != '+' {
+			return n, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+	}
+
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return n, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	v *= multiplier
+	if negative {
+		v = -v
+	}
+	n.Value = v
+	return n, nil
+}
+
+// normalizeSpaces trims the cell and folds the many space characters pages
+// use into plain spaces, except the narrow no-break space that French
+// formatting uses as its group separator
+func normalizeSpaces(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\u202f':
+			b.WriteRune(r)
+		case r == '\u200b', r == '\ufeff': // zero-width
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func trimFirstRune(s string) string {
+	for i := range s {
+		if i > 0 {
+			return s[i:]
+		}
+	}
+	return ""
+}
+
+// cutSign strips a leading sign. U+2212 (minus) and U+2013 (en dash) are what
+// pages actually render for negatives.
+func cutSign(s string) (int, string, bool) {
+	for _, prefix := range []string{"-", "−", "–", "‒", "﹣", "－"} {
+		if strings.HasPrefix(s, prefix) {
+			return -1, s[len(prefix):], true
+		}
+	}
+	if strings.HasPrefix(s, "+") {
+		return 1, s[1:], true
+	}
+	return 0, s, false
+}
+
+func cutCurrency(s string) (string, bool) {
+	for _, c := range currencies {
+		if strings.HasPrefix(s, c) {
+			return s[len(c):], true
+		}
+		if strings.HasSuffix(s, c) {
+			return s[:len(s)-len(c)], true
+		}
+	}
+	return s, false
+}
+
+// cutSuffix strips a magnitude suffix ("1.2B", "3.4 bn", "5 million").
+// Only a trailing word made of letters counts, so digits are never eaten.
+func cutSuffix(s string) (string, float64) {
+	end := len(s)
+	start := end
+	for start > 0 {
+		r := rune(s[start-1])
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			start--
+			continue
+		}
+		break
+	}
+	if start == end {
+		return s, 1
+	}
+
+	word := strings.ToLower(s[start:end])
+	if word == "e" {
+		return s, 1 // bare exponent marker, let ParseFloat reject it
+	}
+	if m, ok := suffixes[word]; ok {
+		return strings.TrimSpace(s[:start]), m
+	}
+	return s, 1
+}
+
+// expandSubscriptZeros rewrites "0.0₅123" as "0.00000123": a subscript
+// count n after a zero stands for n zeros
+func expandSubscriptZeros(s string) (string, error) {
+	if !strings.ContainsAny(s, "₀₁₂₃₄₅₆₇₈₉") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] < '₀' || runes[i] > '₉' {
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		count := 0
+		for ; i < len(runes) && runes[i] >= '₀' && runes[i] <= '₉'; i++ {
+			count = count*10 + int(runes[i]-'₀')
+		}
+		i--
+
+		out := b.String()
+		if !strings.HasSuffix(out, "0") {
+			return "", errors.New("subscript not preceded by a zero")
+		}
+		if count == 0 || count > 40 {
+			return "", fmt.Errorf("unlikely zero count %d", count)
+		}
+		b.Reset()
+		b.WriteString(out[:len(out)-1])
+		b.WriteString(strings.Repeat("0", count))
+	}
+	return b.String(), nil
+}
+
+// applyLocale removes group separators and turns the decimal separator into
+// a dot. Groups must be three digits wide, so "1,41" read as US is rejected
+// instead of silently becoming 141.
+func applyLocale(s string, loc Locale) (string, error) {
+	var b strings.Builder
+	group := -1 // digits since the last group separator, -1 before the first
+	lead := 0   // digits before the first group separator
+	decimal := false
+
+	for _, r := range s {
+		isGroup := r == loc.Group || (loc.Group == '\u202f' && (r == ' ' || r == '\u00a0'))
+		switch {
+		case isGroup:
+			if decimal || (group < 0 && (lead == 0 || lead > 3)) || (group >= 0 && group != 3) {
+				return "", errors.New("misplaced digit group separator")
+			}
+			group = 0
+		case r == loc.Decimal:
+			if group >= 0 && group != 3 {
+				return "", errors.New("misplaced digit group separator")
+			}
+			decimal = true
+			b.WriteRune('.')
+		default:
+			if r >= '0' && r <= '9' && !decimal {
+				if group >= 0 {
+					group++
+				} else {
+					lead++
+				}
+			}
+			b.WriteRune(r)
+		}
+	}
+	if !decimal && group >= 0 && group != 3 {
+		return "", errors.New("misplaced digit group separator")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,165 @@
+package numparse
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var fixtureLocales = map[string]Locale{
+	"us":    LocaleUS,
+	"eu":    LocaleEU,
+	"fr":    LocaleFR,
+	"swiss": LocaleSwiss,
+}
+
+// closeTo compares with a relative tolerance; zero must match exactly
+func closeTo(got, want float64) bool {
+	if want == 0 {
+		return got == 0
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+// TestFixtures runs the parser over testdata/cells.tsv, a corpus of real
+// cell strings seen on CoinMarketCap and CoinGecko pages
+func TestFixtures(t *testing.T) {
+	corpus, err := os.ReadFile("testdata/cells.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for lineNo, line := range strings.Split(string(corpus), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			t.Errorf("line %d: malformed fixture", lineNo+1)
+			continue
+		}
+		loc, ok := fixtureLocales[fields[0]]
+		if !ok {
+			t.Errorf("line %d: unknown locale %q", lineNo+1, fields[0])
+			continue
+		}
+		cell, want := fields[1], fields[2]
+		wantBound := Exact
+		if len(fields) > 3 {
+			switch fields[3] {
+			case "below":
+				wantBound = Below
+			case "above":
+				wantBound = Above
+			}
+		}
+
+		checked++
+		got, err := ParseNumber(cell, loc)
+		switch want {
+		case "ERR_EMPTY":
+			if !errors.Is(err, ErrEmpty) {
+				t.Errorf("line %d: %q: want ErrEmpty, got %v (%v)", lineNo+1, cell, got.Value, err)
+			}
+		case "ERR_INVALID":
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("line %d: %q: want ErrInvalid, got %v (%v)", lineNo+1, cell, got.Value, err)
+			}
+		default:
+			wantValue, perr := strconv.ParseFloat(want, 64)
+			if perr != nil {
+				t.Errorf("line %d: bad expected value %q", lineNo+1, want)
+				continue
+			}
+			if err != nil {
+				t.Errorf("line %d: %q: want %v, got error %v", lineNo+1, cell, wantValue, err)
+				continue
+			}
+			if !closeTo(got.Value, wantValue) || got.Bound != wantBound {
+				t.Errorf("line %d: %q: want %v (bound %d), got %v (bound %d)", lineNo+1, cell, wantValue, wantBound, got.Value, got.Bound)
+			}
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no fixtures found")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		cell string
+		want float64
+		err  error
+	}{
+		{"$0.0₅123", 0.00000123, nil},
+		{"$0.0₁₁2345", 2.345e-12, nil},
+		{"<$0.01", 0.01, nil},
+		{"$0", 0, nil},
+		{"-$0.00", 0, nil},
+		{"$1,41T", 0, ErrInvalid},
+		{"12,34", 0, ErrInvalid},
+		{"N/A", 0, ErrEmpty},
+		{"", 0, ErrEmpty},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.cell)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Parse(%q) = %v, %v; want error %v", tt.cell, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !closeTo(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, %v; want %v", tt.cell, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandSubscriptZeros(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"0.0₅123", "0.00000123", true},
+		{"0.0₁₂5", "0.0000000000005", true},
+		{"1.23", "1.23", true},
+		{"1₅2", "", false},   // subscript must follow a zero
+		{"0.0₀1", "", false}, // zero count
+		{"0.0₉₉1", "", false},
+	}
+	for _, tt := range tests {
+		got, err := expandSubscriptZeros(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("expandSubscriptZeros(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestApplyLocale(t *testing.T) {
+	tests := []struct {
+		in   string
+		loc  Locale
+		want string
+		ok   bool
+	}{
+		{"1,234.56", LocaleUS, "1234.56", true},
+		{"1.234,56", LocaleEU, "1234.56", true},
+		{"1 234,56", LocaleFR, "1234.56", true},
+		{"1 234,56", LocaleFR, "1234.56", true},
+		{"1'234.56", LocaleSwiss, "1234.56", true},
+		{"1,41", LocaleUS, "", false},
+		{"1234,567", LocaleUS, "", false},
+		{"1.234,5.6", LocaleEU, "", false},
+	}
+	for _, tt := range tests {
+		got, err := applyLocale(tt.in, tt.loc)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("applyLocale(%q, %+v) = %q, %v; want %q, ok=%v", tt.in, tt.loc, got, err, tt.want, tt.ok)
+		}
+	}
+}
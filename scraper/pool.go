@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ===== WORKER POOL =====
+// Tokens are scraped by SCRAPE_WORKERS workers, each holding its own browser
+// context (separate cookies and cache) on the shared browser. Page loads are
+// spaced by one politeness limiter for the whole process, so adding workers
+// overlaps slow page loads without hitting the site any faster.
+
+// Politeness enforces a minimum gap between navigations across all workers
+type Politeness struct {
+	mu   sync.Mutex
+	gap  time.Duration
+	next time.Time
+}
+
+func NewPoliteness(gap time.Duration) *Politeness {
+	return &Politeness{gap: gap}
+}
+
+// Wait blocks until this caller may start a navigation
+func (p *Politeness) Wait() {
+	p.mu.Lock()
+	now := time.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(p.gap)
+	p.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
+
+// ContextPool hands out isolated browser contexts of one browser, each with
+// its own identity (browser.go). In HAR mode (see har.go) it opens a fresh
+// context per token instead of reusing the shared ones.
+type ContextPool struct {
+	browser    playwright.Browser
+	contexts   chan playwright.BrowserContext
+	all        []playwright.BrowserContext
+	identities []Identity // of all, by position
+}
+
+func NewContextPool(browser playwright.Browser, size int) (*ContextPool, error) {
+	pool := &ContextPool{browser: browser, contexts: make(chan playwright.BrowserContext, size)}
+	if HAR_MODE != "" {
+		return pool, nil
+	}
+	for i := 0; i < size; i++ {
+		ctx, id, err := newIdentityContext(browser, nil)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("could not create browser context: %w", err)
+		}
+		pool.all = append(pool.all, ctx)
+		pool.identities = append(pool.identities, id)
+		pool.contexts <- ctx
+	}
+	return pool, nil
+}
+
+// Acquire takes a shared context; harName names the page's archive in HAR
+// mode
+func (p *ContextPool) Acquire(harName string) (playwright.BrowserContext, error) {
+	if HAR_MODE != "" {
+		return newHARContext(p.browser, harName)
+	}
+	return <-p.contexts, nil
+}
+
+// Release returns a shared context, or closes a HAR context, which is when
+// a recording is written to disk
+func (p *ContextPool) Release(ctx playwright.BrowserContext) {
+	if HAR_MODE != "" {
+		if err := ctx.Close(); err != nil {
+			log.Printf("Closing HAR context failed: %v", err)
+		}
+		return
+	}
+	p.contexts <- ctx
+}
+
+// Close saves each identity slot's cookies and closes the contexts. With
+// more workers than slots several contexts share a slot; the first one's
+// storage is kept.
+func (p *ContextPool) Close() {
+	saved := make(map[int]bool)
+	for i, ctx := range p.all {
+		if id := p.identities[i]; !saved[id.Index] {
+			saveStorageState(ctx, id)
+			saved[id.Index] = true
+		}
+		ctx.Close()
+	}
+}
+
+type tokenResult struct {
+	index   int
+	token   TokenEntry
+	records []HistoricalData
+	err     error
+}
+
+// scrapeConcurrently runs FetchHistorical for every token on `workers`
+// goroutines and hands the results to write in registry order, whatever
+// order they finish in. Each token's rows are sorted by date first.
+func scrapeConcurrently(p MarketDataProvider, tokens []TokenEntry, r DateRange, workers int, write func(tokenResult)) {
+	jobs := make(chan int)
+	results := make(chan tokenResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				token := tokens[i]
+				fmt.Printf("[%d/%d] 🔍 Scraping %s...\n", i+1, len(tokens), strings.ToUpper(token.ScraperSlug))
+
+				records, err := p.FetchHistorical(token, r)
+				if err != nil {
+					log.Printf("Scrape failed for %s: %v", token.ScraperSlug, err)
+				}
+				sort.SliceStable(records, func(a, b int) bool { return records[a].Date < records[b].Date })
+				results <- tokenResult{index: i, token: token, records: records, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range tokens {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Buffer out-of-order results and flush the longest finished prefix
+	pending := make(map[int]tokenResult)
+	next := 0
+	for res := range results {
+		pending[res.index] = res
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			write(ready)
+			next++
+		}
+	}
+}
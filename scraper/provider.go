@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ===== MARKET DATA PROVIDERS =====
+// Same shape as the API collector's providers: the scrape loop only talks to
+// PROVIDERS, page handling and parsing live in the provider. Each provider has
+// a BaseURL field so it can be pointed at a local httptest server.
+
+var ErrNotSupported = errors.New("not supported by this provider")
+
+type Capabilities struct {
+	Historical bool // FetchHistorical returns daily OHLCV
+	Quotes     bool // FetchQuotes returns current snapshots
+	MaxBatch   int  // tokens per FetchQuotes call
+}
+
+// DateRange is an inclusive range of calendar days
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Quote is a current market snapshot, matching the API collector's
+// current-data columns. Fields a provider does not report are NaN, as in the
+// API's normalized rows; the stores write them as empty cells and NULLs.
+type Quote struct {
+	Timestamp          int64
+	TokenKey           string // registry key
+	TokenID            string // the provider's own id
+	CMCID              int
+	Symbol             string
+	Name               string
+	Slug               string
+	Price              float64
+	MarketCap          float64
+	Volume24h          float64
+	VolumeChange24h    float64
+	High24h            float64
+	Low24h             float64
+	PriceChange24h     float64
+	PercentChange1h    float64
+	PercentChange24h   float64
+	PercentChange7d    float64
+	MarketCapDominance float64
+	CirculatingSupply  float64
+	TotalSupply        float64
+	MaxSupply          float64
+	ATH                float64
+	ATHDate            string
+	LastUpdated        string
+}
+
+type MarketDataProvider interface {
+	Name() string
+	Capabilities() Capabilities
+	FetchHistorical(token TokenEntry, r DateRange) ([]HistoricalData, error)
+	FetchQuotes(tokens []TokenEntry) ([]Quote, error)
+}
+
+var PROVIDERS []MarketDataProvider
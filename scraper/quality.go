@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===== DATA QUALITY CHECKS =====
+// Every row is checked before it reaches the store. Rows that break a rule
+// are not written; they go to QUARANTINE_PATH with the rules they broke and
+// the row itself as JSON, and the run ends with a count per rule.
+//
+// This file is kept identical in api/ and scraper/ (the two programs are
+// separate modules); a test in each fails when the copies differ. The
+// history checks differ per program and live in quality_history.go.
+
+// SUPPLY_TOLERANCE absorbs rounding when providers report circulating supply
+// a hair above total supply
+const SUPPLY_TOLERANCE = 0.001
+
+// Violation is one rule a row broke
+type Violation struct {
+	Rule   string
+	Detail string
+}
+
+// checkQuote checks a current snapshot. A field the provider did not report
+// is NaN, which fails every comparison, or 0; neither is flagged.
+func checkQuote(q Quote) []Violation {
+	var v []Violation
+	if q.Price <= 0 {
+		v = append(v, Violation{"non_positive_price", fmt.Sprintf("price=%g", q.Price)})
+	}
+	if q.Volume24h < 0 {
+		v = append(v, Violation{"negative_volume", fmt.Sprintf("volume_24h=%g", q.Volume24h)})
+	}
+	if q.MarketCap < 0 {
+		v = append(v, Violation{"negative_market_cap", fmt.Sprintf("market_cap=%g", q.MarketCap)})
+	}
+	if q.High24h > 0 && q.Low24h > 0 && q.High24h < q.Low24h {
+		v = append(v, Violation{"high_below_low", fmt.Sprintf("high_24h=%g low_24h=%g", q.High24h, q.Low24h)})
+	}
+	if q.CirculatingSupply < 0 || q.TotalSupply < 0 || q.MaxSupply < 0 {
+		v = append(v, Violation{"negative_supply", fmt.Sprintf("circulating=%g total=%g max=%g",
+			q.CirculatingSupply, q.TotalSupply, q.MaxSupply)})
+	}
+	if q.CirculatingSupply > 0 && q.TotalSupply > 0 && q.CirculatingSupply > q.TotalSupply*(1+SUPPLY_TOLERANCE) {
+		v = append(v, Violation{"circulating_above_total", fmt.Sprintf("circulating=%g total=%g",
+			q.CirculatingSupply, q.TotalSupply)})
+	}
+	if q.TotalSupply > 0 && q.MaxSupply > 0 && q.TotalSupply > q.MaxSupply*(1+SUPPLY_TOLERANCE) {
+		v = append(v, Violation{"total_above_max", fmt.Sprintf("total=%g max=%g", q.TotalSupply, q.MaxSupply)})
+	}
+	return v
+}
+
+// dayOf truncates t to midnight UTC
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Quarantine collects rejected rows and counts checks per rule
+type Quarantine struct {
+	Path string
+
+	mu       sync.Mutex
+	checked  int
+	rejected int
+	rules    map[string]int
+}
+
+func NewQuarantine(path string) *Quarantine {
+	return &Quarantine{Path: path, rules: make(map[string]int)}
+}
+
+// validQuotes returns the quotes that pass, quarantining the rest
+func (q *Quarantine) validQuotes(source string, quotes []Quote) []Quote {
+	var valid []Quote
+	for _, quote := range quotes {
+		if v := checkQuote(quote); len(v) > 0 {
+			q.add(source, quote.TokenKey, time.Unix(quote.Timestamp, 0).Format("2006-01-02"), v, quote)
+			continue
+		}
+		valid = append(valid, quote)
+	}
+	q.count(len(quotes))
+	return valid
+}
+
+func (q *Quarantine) count(n int) {
+	q.mu.Lock()
+	q.checked += n
+	q.mu.Unlock()
+}
+
+// add appends one rejected row to the quarantine file
+func (q *Quarantine) add(source, token, date string, violations []Violation, row any) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rejected++
+	var rules, details []string
+	for _, v := range violations {
+		q.rules[v.Rule]++
+		rules = append(rules, v.Rule)
+		details = append(details, v.Detail)
+	}
+	log.Printf("Quarantined %s %s %s: %s", source, token, date, strings.Join(details, "; "))
+
+	body, err := json.Marshal(row)
+	if err != nil {
+		body = []byte(fmt.Sprintf("%+v", row)) // NaN fields have no JSON form
+	}
+	record := []string{
+		time.Now().UTC().Format(time.RFC3339), source, token, date,
+		strings.Join(rules, ";"), strings.Join(details, "; "), string(body),
+	}
+	if err := appendQuarantine(q.Path, record); err != nil {
+		log.Printf("Could not write quarantine %s: %v", q.Path, err)
+	}
+}
+
+func appendQuarantine(path string, record []string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := writer.Write([]string{"quarantined_at", "source", "token", "date", "rules", "details", "row"}); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Rules returns the number of rows that broke each rule
+func (q *Quarantine) Rules() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rules := make(map[string]int, len(q.rules))
+	for name, n := range q.rules {
+		rules[name] = n
+	}
+	return rules
+}
+
+// Report prints the checks since the last report and starts counting afresh
+func (q *Quarantine) Report() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.checked == 0 {
+		return
+	}
+	fmt.Printf("🧪 Validation: %d rows checked, %d quarantined\n", q.checked, q.rejected)
+	if q.rejected > 0 {
+		names := make([]string, 0, len(q.rules))
+		for name := range q.rules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("   - %-24s %d\n", name, q.rules[name])
+		}
+		fmt.Printf("   Rejected rows: %s\n", q.Path)
+	}
+	q.checked, q.rejected = 0, 0
+	q.rules = make(map[string]int)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ===== HISTORY CHECKS =====
+// The OHLC rules only the history pages give us; the shared rules and the
+// quarantine are in quality.go.
+
+// checkHistorical checks a scraped day requested for r. A cell that did not
+// parse is left at 0, so a zero open, high, low or volume is a missing value.
+func checkHistorical(d HistoricalData, r DateRange) []Violation {
+	var v []Violation
+	if d.Close <= 0 {
+		v = append(v, Violation{"non_positive_price", fmt.Sprintf("close=%g", d.Close)})
+	}
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{{"open", d.Open}, {"high", d.High}, {"low", d.Low}, {"volume", d.Volume}} {
+		if f.value == 0 {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		v = append(v, Violation{"missing_value", strings.Join(missing, ",") + "=0"})
+	}
+	if d.High > 0 && d.High < max(d.Open, d.Close) {
+		v = append(v, Violation{"high_below_open_close", fmt.Sprintf("high=%g open=%g close=%g", d.High, d.Open, d.Close)})
+	}
+	if d.Low > 0 && d.Low > min(d.Open, d.Close) {
+		v = append(v, Violation{"low_above_open_close", fmt.Sprintf("low=%g open=%g close=%g", d.Low, d.Open, d.Close)})
+	}
+	if d.Volume < 0 {
+		v = append(v, Violation{"negative_volume", fmt.Sprintf("volume=%g", d.Volume)})
+	}
+	if d.MarketCap < 0 {
+		v = append(v, Violation{"negative_market_cap", fmt.Sprintf("market_cap=%g", d.MarketCap)})
+	}
+	if date, err := time.Parse("2006-01-02", d.Date); err != nil {
+		v = append(v, Violation{"bad_date", fmt.Sprintf("date=%q", d.Date)})
+	} else if !r.From.IsZero() && (date.Before(dayOf(r.From)) || date.After(dayOf(r.To))) {
+		v = append(v, Violation{"date_out_of_range", fmt.Sprintf("%s not in %s..%s",
+			d.Date, dayOf(r.From).Format("2006-01-02"), dayOf(r.To).Format("2006-01-02"))})
+	}
+	return v
+}
+
+// validRecords returns the records that pass, quarantining the rest
+func (q *Quarantine) validRecords(source string, r DateRange, records []HistoricalData) []HistoricalData {
+	var valid []HistoricalData
+	for _, d := range records {
+		if v := checkHistorical(d, r); len(v) > 0 {
+			q.add(source, d.TokenKey, d.Date, v, d)
+			continue
+		}
+		valid = append(valid, d)
+	}
+	q.count(len(records))
+	return valid
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ===== TOKEN REGISTRY =====
+// Same ../tokens.json file the API collector loads. Run `go run . validate`
+// in ../api to check it for missing mappings.
+
+const (
+	SOURCE_CMC_SCRAPER = "cmc_scraper"
+	SOURCE_CG_SCRAPER  = "cg_scraper"
+)
+
+type TokenRegistry struct {
+	Sources map[string]bool `json:"sources"`
+	Tokens  []TokenEntry    `json:"tokens"`
+}
+
+type TokenEntry struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Symbol      string            `json:"symbol"`
+	CoinGeckoID string            `json:"coingecko_id"`
+	CMC         CMCMapping        `json:"cmc"`
+	ScraperSlug string            `json:"scraper_slug"`
+	Contracts   map[string]string `json:"contracts,omitempty"` // chain -> contract address
+}
+
+type CMCMapping struct {
+	ID     int    `json:"id"`
+	Slug   string `json:"slug"`
+	Symbol string `json:"symbol"`
+}
+
+func loadTokenRegistry(path string) (*TokenRegistry, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reg TokenRegistry
+	if err := json.Unmarshal(body, &reg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	// Same key checks as the collector's loader. The ids the scraper files
+	// rows under must be unique too, or two tokens would share history.
+	seen := make(map[string]bool)
+	ids := make(map[string]string)
+	for i, t := range reg.Tokens {
+		if t.Key == "" {
+			return nil, fmt.Errorf("token #%d in %s has no key", i+1, path)
+		}
+		if seen[t.Key] {
+			return nil, fmt.Errorf("duplicate token key %q in %s", t.Key, path)
+		}
+		seen[t.Key] = true
+
+		for _, id := range []struct{ field, value string }{
+			{"scraper_slug", t.ScraperSlug},
+			{"coingecko_id", t.CoinGeckoID},
+			{"cmc.id", strconv.Itoa(t.CMC.ID)},
+		} {
+			if id.value == "" || id.value == "0" {
+				continue
+			}
+			if other, ok := ids[id.field+"|"+id.value]; ok {
+				return nil, fmt.Errorf("%s: %s %q already used by %s in %s", t.Key, id.field, id.value, other, path)
+			}
+			ids[id.field+"|"+id.value] = t.Key
+		}
+	}
+
+	return &reg, nil
+}
+
+// Enabled reports whether a source is switched on in the registry
+func (r *TokenRegistry) Enabled(source string) bool {
+	return r.Sources[source]
+}
+
+// Find looks a token up by its registry key
+func (r *TokenRegistry) Find(key string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// FindBySlug looks a token up by its scraper slug
+func (r *TokenRegistry) FindBySlug(slug string) (TokenEntry, bool) {
+	for _, t := range r.Tokens {
+		if t.ScraperSlug == slug {
+			return t, true
+		}
+	}
+	return TokenEntry{}, false
+}
+
+// ScraperTokens returns the tokens that have a CMC page slug. Keys of tokens
+// without one are returned separately so the caller can report them.
+func (r *TokenRegistry) ScraperTokens() (tokens []TokenEntry, unmapped []string) {
+	for _, t := range r.Tokens {
+		if t.ScraperSlug == "" {
+			unmapped = append(unmapped, t.Key)
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, unmapped
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ===== CSV SCHEMA VERSIONS =====
+// This file is the one definition of the CSV schemas and is kept identical in
+// api/ and scraper/ (the two programs are separate modules); a test in each
+// fails when the copies differ. Change it in one place and copy it over.
+//
+// Every CSV either program writes is an instance of a dataset schema. A
+// file's version is the one whose column list matches its header exactly;
+// from v2 on each row also carries it in the schema_version column. Before
+// appending, a program upgrades a file in an older version to the current
+// one (ensureSchema, keeping path.v<N>.bak) and refuses a header it does not
+// know. Readers upgrade rows in memory the same way (upgradeRow), so they
+// only ever see the current columns. SQLite tables are not versioned.
+//
+// To change a dataset's columns, add a version to its Versions with the new
+// column list, the columns it renames (Renamed), the value of each new column
+// for rows written before it (Defaults) and the columns it drops (Dropped).
+// A column the migration cannot account for is an error, never a guess.
+
+const SCHEMA_VERSION_COLUMN = "schema_version"
+
+type CSVSchema struct {
+	Version  int
+	Columns  []string
+	Renamed  map[string]string // new column -> column in the previous version
+	Defaults map[string]string // new column -> value for migrated rows
+	Dropped  []string          // previous columns left out of this version
+}
+
+type Dataset struct {
+	Name     string
+	Versions []CSVSchema // oldest first
+}
+
+var (
+	// CoinGecko current data: the collector's cg_data CSVs and the scraper's
+	// page snapshots
+	CG_DATASET = Dataset{
+		Name: "coingecko",
+		Versions: []CSVSchema{
+			{
+				Version: 1,
+				Columns: []string{
+					"timestamp", "date", "token_id", "symbol", "name",
+					"price", "market_cap", "total_volume",
+					"high_24h", "low_24h", "price_change_24h", "price_change_percentage_24h",
+					"circulating_supply", "total_supply", "ath", "ath_date", "source",
+				},
+			},
+			{
+				Version: 2,
+				Columns: []string{
+					"timestamp", "date", "token_id", "symbol", "name",
+					"price", "market_cap", "total_volume",
+					"high_24h", "low_24h", "price_change_24h", "price_change_percentage_24h",
+					"circulating_supply", "total_supply", "ath", "ath_date", "source",
+					SCHEMA_VERSION_COLUMN,
+				},
+			},
+		},
+	}
+
+	// CoinMarketCap API quotes (the collector's cmc_data CSVs)
+	CMC_DATASET = Dataset{
+		Name: "coinmarketcap",
+		Versions: []CSVSchema{
+			{
+				Version: 1,
+				Columns: []string{
+					"timestamp", "date", "symbol", "name", "slug",
+					"price", "volume_24h", "volume_change_24h",
+					"percent_change_1h", "percent_change_24h", "percent_change_7d",
+					"market_cap", "market_cap_dominance",
+					"circulating_supply", "total_supply", "max_supply",
+					"last_updated", "source",
+				},
+			},
+			{
+				Version: 2,
+				Columns: []string{
+					"timestamp", "date", "symbol", "name", "slug",
+					"price", "volume_24h", "volume_change_24h",
+					"percent_change_1h", "percent_change_24h", "percent_change_7d",
+					"market_cap", "market_cap_dominance",
+					"circulating_supply", "total_supply", "max_supply",
+					"last_updated", "source",
+					SCHEMA_VERSION_COLUMN,
+				},
+			},
+		},
+	}
+
+	// CoinMarketCap history scraped from the pages (the scraper's merged
+	// history file)
+	CMC_SCRAPER_DATASET = Dataset{
+		Name: "coinmarketcap_scraper",
+		Versions: []CSVSchema{
+			{
+				Version: 1,
+				Columns: []string{
+					"date", "token_symbol", "token_name",
+					"open", "high", "low", "close", "volume", "market_cap", "source",
+				},
+			},
+		},
+	}
+)
+
+// Current is the version new rows are written in
+func (d *Dataset) Current() CSVSchema {
+	return d.Versions[len(d.Versions)-1]
+}
+
+// Identify returns the version whose columns match header
+func (d *Dataset) Identify(header []string) (CSVSchema, bool) {
+	for _, s := range d.Versions {
+		if slices.Equal(s.Columns, header) {
+			return s, true
+		}
+	}
+	return CSVSchema{}, false
+}
+
+// identifyHeader is Identify with an error naming the file
+func (d *Dataset) identifyHeader(path string, header []string) (CSVSchema, error) {
+	s, ok := d.Identify(header)
+	if !ok {
+		return s, fmt.Errorf("%s does not match any %s schema version (header: %s)",
+			path, d.Name, strings.Join(header, ","))
+	}
+	return s, nil
+}
+
+// versionField is the schema_version cell of rows written now
+func (d *Dataset) versionField() string {
+	return strconv.Itoa(d.Current().Version)
+}
+
+func readCSVHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return header, err
+}
+
+// checkSchema returns an error unless the file's header is the dataset's
+// current version
+func checkSchema(path string, d *Dataset) error {
+	header, err := readCSVHeader(path)
+	if err != nil {
+		return err
+	}
+	s, err := d.identifyHeader(path, header)
+	if err != nil {
+		return err
+	}
+	if current := d.Current(); s.Version < current.Version {
+		return fmt.Errorf("%s is %s schema v%d, the current version is v%d",
+			path, d.Name, s.Version, current.Version)
+	}
+	return nil
+}
+
+// ensureSchema makes an existing file ready to append to: a file in an older
+// version is migrated to the current one, an unknown header is an error. It
+// returns the version the file was in; a missing or empty file is left for
+// the caller to create.
+func ensureSchema(path string, d *Dataset) (from int, err error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		return d.Current().Version, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	from, _, _, err = migrateCSV(path, d, false)
+	return from, err
+}
+
+// migrateStep maps a row of the previous version to s. Columns are matched
+// by name, through Renamed; new ones take their default.
+func (s CSVSchema) migrateStep(prev CSVSchema, row []string) ([]string, error) {
+	index := make(map[string]int, len(prev.Columns))
+	for i, col := range prev.Columns {
+		index[col] = i
+	}
+
+	out := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		if col == SCHEMA_VERSION_COLUMN {
+			out[i] = strconv.Itoa(s.Version)
+			continue
+		}
+		from := col
+		if renamed, ok := s.Renamed[col]; ok {
+			from = renamed
+		}
+		if j, ok := index[from]; ok {
+			out[i] = row[j]
+			continue
+		}
+		value, ok := s.Defaults[col]
+		if !ok {
+			return nil, fmt.Errorf("v%d column %q has no source column or default", s.Version, col)
+		}
+		out[i] = value
+	}
+	return out, nil
+}
+
+// checkMapping makes sure every column of prev is carried into s or dropped
+func (s CSVSchema) checkMapping(prev CSVSchema) error {
+	carried := make(map[string]bool)
+	for _, col := range s.Columns {
+		from := col
+		if renamed, ok := s.Renamed[col]; ok {
+			from = renamed
+		}
+		carried[from] = true
+	}
+	for _, col := range prev.Columns {
+		if !carried[col] && !slices.Contains(s.Dropped, col) {
+			return fmt.Errorf("v%d column %q is neither kept, renamed nor dropped in v%d", prev.Version, col, s.Version)
+		}
+	}
+	return nil
+}
+
+// upgradeRow maps a row written in version from to the current version
+func (d *Dataset) upgradeRow(from CSVSchema, row []string) ([]string, error) {
+	if len(row) != len(from.Columns) {
+		return nil, fmt.Errorf("%d fields, v%d has %d", len(row), from.Version, len(from.Columns))
+	}
+	prev := from
+	for _, step := range d.Versions[from.Version:] {
+		if err := step.checkMapping(prev); err != nil {
+			return nil, err
+		}
+		var err error
+		if row, err = step.migrateStep(prev, row); err != nil {
+			return nil, err
+		}
+		prev = step
+	}
+	return row, nil
+}
+
+// migrateCSV rewrites the file at path in the dataset's current version,
+// keeping the original as path.v<N>.bak, and returns the versions and rows
+func migrateCSV(path string, d *Dataset, dryRun bool) (from, to, rows int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("reading header: %w", err)
+	}
+	s, err := d.identifyHeader(path, header)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	current := d.Current()
+	if s.Version == current.Version {
+		return s.Version, s.Version, 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	writer := csv.NewWriter(tmp)
+	if err := writer.Write(current.Columns); err != nil {
+		return 0, 0, 0, err
+	}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if row, err = d.upgradeRow(s, row); err != nil {
+			return 0, 0, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := writer.Write(row); err != nil {
+			return 0, 0, 0, err
+		}
+		rows++
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, 0, 0, err
+	}
+	if dryRun {
+		return s.Version, current.Version, rows, nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, s.Version)
+	if err := os.Rename(path, backup); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, 0, 0, err
+	}
+	log.Printf("Migrated %s from %s v%d to v%d (%d rows, backup %s)", path, d.Name, s.Version, current.Version, rows, backup)
+	return s.Version, current.Version, rows, nil
+}
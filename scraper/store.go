@@ -0,0 +1,397 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ===== STORAGE =====
+// Same backends as the API collector, selected with STORE_BACKEND=csv|sqlite.
+// The scraper's table is called ohlcv so both programs can share one
+// database file through SQLITE_PATH.
+
+const (
+	STORE_CSV    = "csv"
+	STORE_SQLITE = "sqlite"
+)
+
+type Store interface {
+	WriteHistorical(source string, records []HistoricalData) (MergeStats, error)
+	WriteQuotes(source string, quotes []Quote) error
+	Close() error
+}
+
+// openStore opens the backend chosen by STORE_BACKEND. Existing history is
+// kept and merged into (see merge.go).
+func openStore() Store {
+	switch STORE_BACKEND {
+	case STORE_CSV:
+		return &CSVStore{Path: CMC_CSV_PATH, QuotesPath: CG_SCRAPER_CSV_PATH}
+	case STORE_SQLITE:
+		store, err := NewSQLiteStore(SQLITE_PATH)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		fmt.Printf("💾 SQLite store: %s\n", SQLITE_PATH)
+		return store
+	}
+	log.Fatalf("Unknown STORE_BACKEND %q (use csv or sqlite)", STORE_BACKEND)
+	return nil
+}
+
+// outputPath is where the configured backend writes
+func outputPath() string {
+	if STORE_BACKEND == STORE_SQLITE {
+		return SQLITE_PATH
+	}
+	return CMC_CSV_PATH
+}
+
+// quotesOutputPath is where the configured backend writes quotes
+func quotesOutputPath() string {
+	if STORE_BACKEND == STORE_SQLITE {
+		return SQLITE_PATH
+	}
+	return CG_SCRAPER_CSV_PATH
+}
+
+// ===== CSV STORE =====
+type CSVStore struct {
+	Path       string // CMC history
+	QuotesPath string // CoinGecko page snapshots
+
+	backedUp bool
+}
+
+// WriteHistorical merges records into the history file and rewrites it
+// atomically, backing up the previous version on the run's first write
+func (s *CSVStore) WriteHistorical(source string, records []HistoricalData) (MergeStats, error) {
+	rows, err := readHistoryCSV(s.Path)
+	if err != nil {
+		return MergeStats{}, err
+	}
+	rows, stats := mergeHistory(rows, records)
+	if stats.Added == 0 && stats.Updated == 0 {
+		return stats, nil
+	}
+
+	if !s.backedUp {
+		if err := backupFile(s.Path); err != nil {
+			return stats, fmt.Errorf("backing up %s: %w", s.Path, err)
+		}
+		s.backedUp = true
+	}
+	return stats, writeCSVAtomic(s.Path, HISTORY_CSV_HEADER, rows)
+}
+
+// WriteQuotes appends snapshots in the current CoinGecko schema the API
+// collector writes its cg_data CSVs in (schema.go), creating the file on
+// first use and upgrading one in an older version before appending.
+// Fields the page did not show are left empty; a real zero is written as 0.
+func (s *CSVStore) WriteQuotes(source string, quotes []Quote) error {
+	if _, err := ensureSchema(s.QuotesPath, &CG_DATASET); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.QuotesPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := writer.Write(CG_DATASET.Current().Columns); err != nil {
+			return err
+		}
+	}
+
+	for _, q := range quotes {
+		record := []string{
+			strconv.FormatInt(q.Timestamp, 10),
+			time.Unix(q.Timestamp, 0).Format("2006-01-02"),
+			q.TokenID,
+			q.Symbol,
+			q.Name,
+			fmt.Sprintf("%.8f", q.Price),
+			optional("%.2f", q.MarketCap),
+			optional("%.2f", q.Volume24h),
+			optional("%.8f", q.High24h),
+			optional("%.8f", q.Low24h),
+			optional("%.8f", q.PriceChange24h),
+			optional("%.4f", q.PercentChange24h),
+			optional("%.2f", q.CirculatingSupply),
+			optional("%.2f", q.TotalSupply),
+			optional("%.8f", q.ATH),
+			q.ATHDate,
+			"coingecko_scraper",
+			CG_DATASET.versionField(),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// optional formats v, or returns "" for a value the provider did not report
+func optional(format string, v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return fmt.Sprintf(format, v)
+}
+
+func (s *CSVStore) Close() error { return nil }
+
+// ===== SQLITE STORE =====
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS ohlcv (
+	source     TEXT    NOT NULL,
+	token      TEXT    NOT NULL,
+	timestamp  INTEGER NOT NULL,
+	date       TEXT    NOT NULL,
+	symbol     TEXT,
+	name       TEXT,
+	cmc_id     INTEGER,
+	open       REAL,
+	high       REAL,
+	low        REAL,
+	close      REAL,
+	volume     REAL,
+	market_cap REAL,
+	PRIMARY KEY (source, token, date)
+);
+CREATE INDEX IF NOT EXISTS idx_ohlcv_token_date ON ohlcv (token, date);
+
+-- Same table as the API collector's, so quotes from both land together
+CREATE TABLE IF NOT EXISTS quotes (
+	source               TEXT    NOT NULL,
+	token                TEXT    NOT NULL,
+	timestamp            INTEGER NOT NULL,
+	date                 TEXT    NOT NULL,
+	provider_id          TEXT,
+	cmc_id               INTEGER,
+	symbol               TEXT,
+	name                 TEXT,
+	slug                 TEXT,
+	price                REAL,
+	market_cap           REAL,
+	volume_24h           REAL,
+	volume_change_24h    REAL,
+	high_24h             REAL,
+	low_24h              REAL,
+	price_change_24h     REAL,
+	percent_change_1h    REAL,
+	percent_change_24h   REAL,
+	percent_change_7d    REAL,
+	market_cap_dominance REAL,
+	circulating_supply   REAL,
+	total_supply         REAL,
+	max_supply           REAL,
+	ath                  REAL,
+	ath_date             TEXT,
+	last_updated         TEXT,
+	PRIMARY KEY (source, token, timestamp)
+);
+CREATE INDEX IF NOT EXISTS idx_quotes_token_date ON quotes (token, date);
+`
+
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+
+	backedUp bool
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// WriteHistorical upserts one row per token and day; the timestamp is the
+// day's midnight UTC. Rows whose values are unchanged are not touched.
+func (s *SQLiteStore) WriteHistorical(source string, records []HistoricalData) (MergeStats, error) {
+	var stats MergeStats
+	if len(records) == 0 {
+		return stats, nil
+	}
+	if !s.backedUp {
+		if err := s.backup(); err != nil {
+			return stats, fmt.Errorf("backing up %s: %w", s.path, err)
+		}
+		s.backedUp = true
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	token := records[0].TokenKey
+	countRows := func() (int, error) {
+		var n int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM ohlcv WHERE source = ? AND token = ?`, source, token).Scan(&n)
+		return n, err
+	}
+	before, err := countRows()
+	if err != nil {
+		return stats, err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO ohlcv (source, token, timestamp, date, symbol, name, cmc_id, open, high, low, close, volume, market_cap)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, token, date) DO UPDATE SET
+			timestamp = excluded.timestamp,
+			symbol = excluded.symbol,
+			name = excluded.name,
+			cmc_id = excluded.cmc_id,
+			open = excluded.open,
+			high = excluded.high,
+			low = excluded.low,
+			close = excluded.close,
+			volume = excluded.volume,
+			market_cap = excluded.market_cap
+		WHERE symbol IS NOT excluded.symbol
+			OR name IS NOT excluded.name
+			OR cmc_id IS NOT excluded.cmc_id
+			OR open IS NOT excluded.open
+			OR high IS NOT excluded.high
+			OR low IS NOT excluded.low
+			OR close IS NOT excluded.close
+			OR volume IS NOT excluded.volume
+			OR market_cap IS NOT excluded.market_cap`)
+	if err != nil {
+		return stats, err
+	}
+	defer stmt.Close()
+
+	changed := 0
+	for _, d := range records {
+		day, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			return stats, fmt.Errorf("bad date %q for %s: %w", d.Date, d.TokenKey, err)
+		}
+		res, err := stmt.Exec(source, d.TokenKey, day.Unix(), d.Date, d.TokenSymbol, d.TokenName, d.CMCID,
+			d.Open, d.High, d.Low, d.Close, d.Volume, d.MarketCap)
+		if err != nil {
+			return stats, err
+		}
+		n, _ := res.RowsAffected()
+		changed += int(n)
+	}
+
+	// An insert and an update both count as a change; the row count tells
+	// them apart (records are one token's, as scrapeConcurrently hands them)
+	after, err := countRows()
+	if err != nil {
+		return stats, err
+	}
+	stats.Added = after - before
+	stats.Updated = changed - stats.Added
+	stats.Unchanged = len(records) - changed
+	return stats, tx.Commit()
+}
+
+// backup snapshots the database to path.bak before the run changes it
+func (s *SQLiteStore) backup() error {
+	if err := os.Remove(s.path + ".bak"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := s.db.Exec(`VACUUM INTO ?`, s.path+".bak")
+	return err
+}
+
+// WriteQuotes upserts snapshots keyed by registry key and scrape time
+func (s *SQLiteStore) WriteQuotes(source string, quotes []Quote) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO quotes (
+			source, token, timestamp, date, provider_id, cmc_id, symbol, name, slug,
+			price, market_cap, volume_24h, volume_change_24h, high_24h, low_24h,
+			price_change_24h, percent_change_1h, percent_change_24h, percent_change_7d,
+			market_cap_dominance, circulating_supply, total_supply, max_supply,
+			ath, ath_date, last_updated
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, token, timestamp) DO UPDATE SET
+			date = excluded.date,
+			provider_id = excluded.provider_id,
+			cmc_id = excluded.cmc_id,
+			symbol = excluded.symbol,
+			name = excluded.name,
+			slug = excluded.slug,
+			price = excluded.price,
+			market_cap = excluded.market_cap,
+			volume_24h = excluded.volume_24h,
+			volume_change_24h = excluded.volume_change_24h,
+			high_24h = excluded.high_24h,
+			low_24h = excluded.low_24h,
+			price_change_24h = excluded.price_change_24h,
+			percent_change_1h = excluded.percent_change_1h,
+			percent_change_24h = excluded.percent_change_24h,
+			percent_change_7d = excluded.percent_change_7d,
+			market_cap_dominance = excluded.market_cap_dominance,
+			circulating_supply = excluded.circulating_supply,
+			total_supply = excluded.total_supply,
+			max_supply = excluded.max_supply,
+			ath = excluded.ath,
+			ath_date = excluded.ath_date,
+			last_updated = excluded.last_updated`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, q := range quotes {
+		token := q.TokenKey
+		if token == "" {
+			token = q.TokenID
+		}
+		_, err := stmt.Exec(
+			source, token, q.Timestamp, time.Unix(q.Timestamp, 0).Format("2006-01-02"),
+			q.TokenID, q.CMCID, q.Symbol, q.Name, q.Slug,
+			q.Price, q.MarketCap, q.Volume24h, q.VolumeChange24h, q.High24h, q.Low24h,
+			q.PriceChange24h, q.PercentChange1h, q.PercentChange24h, q.PercentChange7d,
+			q.MarketCapDominance, q.CirculatingSupply, q.TotalSupply, q.MaxSupply,
+			q.ATH, q.ATHDate, q.LastUpdated,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOptional(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{math.NaN(), ""},
+		{0, "0.00"},
+		{-1.5, "-1.50"},
+		{1234.567, "1234.57"},
+	}
+	for _, tt := range tests {
+		if got := optional("%.2f", tt.v); got != tt.want {
+			t.Errorf("optional(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func testQuote() Quote {
+	nan := math.NaN()
+	return Quote{
+		Timestamp: 1700000000, TokenKey: "eth", TokenID: "ethereum", Symbol: "ETH", Name: "Ethereum",
+		Price: 2000, MarketCap: nan, Volume24h: 0, VolumeChange24h: nan, High24h: nan, Low24h: nan,
+		PriceChange24h: nan, PercentChange1h: nan, PercentChange24h: nan, PercentChange7d: nan,
+		MarketCapDominance: nan, CirculatingSupply: nan, TotalSupply: nan, MaxSupply: nan, ATH: nan,
+	}
+}
+
+func TestCSVQuotesKeepMissingApartFromZero(t *testing.T) {
+	store := &CSVStore{QuotesPath: filepath.Join(t.TempDir(), "quotes.csv")}
+	if err := store.WriteQuotes(SOURCE_CG_SCRAPER, []Quote{testQuote()}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(store.QuotesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d lines, want header and one row", len(records))
+	}
+	row := make(map[string]string)
+	for i, col := range records[0] {
+		row[col] = records[1][i]
+	}
+	if row["market_cap"] != "" || row["total_volume"] != "0.00" {
+		t.Errorf("market_cap=%q total_volume=%q, want empty and 0.00", row["market_cap"], row["total_volume"])
+	}
+}
+
+// The snapshots are in the collector's current cg_data columns
+func TestCSVQuotesWriteCollectorSchema(t *testing.T) {
+	store := &CSVStore{QuotesPath: filepath.Join(t.TempDir(), "quotes.csv")}
+	for range 2 {
+		if err := store.WriteQuotes(SOURCE_CG_SCRAPER, []Quote{testQuote()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(store.QuotesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := CG_DATASET.Current().Columns; !slices.Equal(records[0], want) {
+		t.Fatalf("header = %q, want %q", records[0], want)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d lines, want header and two rows", len(records))
+	}
+	for _, row := range records[1:] {
+		if len(row) != 18 || row[17] != CG_DATASET.versionField() {
+			t.Errorf("row %q, want 18 fields ending in schema_version %s", row, CG_DATASET.versionField())
+		}
+	}
+}
+
+func TestSQLiteQuotesStoreMissingAsNull(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "scraper.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.WriteQuotes(SOURCE_CG_SCRAPER, []Quote{testQuote()}); err != nil {
+		t.Fatal(err)
+	}
+
+	var marketCap, volume sql.NullFloat64
+	err = store.db.QueryRow(`SELECT market_cap, volume_24h FROM quotes`).Scan(&marketCap, &volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marketCap.Valid || !volume.Valid || volume.Float64 != 0 {
+		t.Errorf("market_cap=%+v volume_24h=%+v, want NULL and 0", marketCap, volume)
+	}
+}
+
+func TestCSVQuotesRefuseOtherSchema(t *testing.T) {
+	store := &CSVStore{QuotesPath: filepath.Join(t.TempDir(), "quotes.csv")}
+	if err := os.WriteFile(store.QuotesPath, []byte("timestamp,price\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteQuotes(SOURCE_CG_SCRAPER, []Quote{testQuote()}); err == nil {
+		t.Fatal("appended to a file in an unknown schema")
+	}
+	body, err := os.ReadFile(store.QuotesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "timestamp,price\n" {
+		t.Errorf("file changed to %q", body)
+	}
+}
+
+func TestCSVQuotesUpgradeOlderFile(t *testing.T) {
+	store := &CSVStore{QuotesPath: filepath.Join(t.TempDir(), "quotes.csv")}
+	v1 := CG_DATASET.Versions[0].Columns
+	old := make([]string, len(v1))
+	old[0] = "1690000000"
+	file, err := os.Create(store.QuotesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll([][]string{v1, old}); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if err := store.WriteQuotes(SOURCE_CG_SCRAPER, []Quote{testQuote()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSchema(store.QuotesPath, &CG_DATASET); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.QuotesPath + ".v1.bak"); err != nil {
+		t.Errorf("no backup of the v1 file: %v", err)
+	}
+
+	file, err = os.Open(store.QuotesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "1690000000" {
+		t.Fatalf("got %q, want the upgraded row then the new one", records)
+	}
+	version := CG_DATASET.versionField()
+	for _, row := range records[1:] {
+		if row[len(row)-1] != version {
+			t.Errorf("row %q has schema_version %q, want %q", row, row[len(row)-1], version)
+		}
+	}
+}